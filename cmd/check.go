@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/goodieshq/revokr/pkg/crl"
+	"github.com/goodieshq/revokr/pkg/util"
+	"github.com/rs/zerolog/log"
+	"github.com/urfave/cli/v3"
+)
+
+// verifiedCRL is a CRL given to check together with the issuer certificate it was verified against.
+type verifiedCRL struct {
+	path   string
+	rl     *x509.RevocationList
+	issuer *x509.Certificate
+	delta  bool
+}
+
+func cmdCheck(_ context.Context, c *cli.Command) error {
+	format := c.String("format")
+	if format != "text" && format != "json" {
+		return cli.Exit(fmt.Sprintf("invalid --format %q, expected text or json", format), 1)
+	}
+
+	crlPaths := c.StringSlice("crl")
+	if len(crlPaths) == 0 {
+		return cli.Exit("at least one CRL must be specified with --crl/-l", 1)
+	}
+	if c.String("crt") == "" {
+		return cli.Exit("issuer certificate or chain of the CRLs must be specified with --crt/-c", 1)
+	}
+	if len(c.StringSlice("certs")) == 0 && len(c.StringSlice("serial")) == 0 {
+		return cli.Exit("certificates to check must be specified with --certs or --serial", 1)
+	}
+
+	issuers, err := util.ReadCertificates(c.String("crt"), "")
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("failed to parse issuer certificate: %v", err), 1)
+	}
+
+	at, err := readAt(c)
+	if err != nil {
+		return err
+	}
+
+	// verify every CRL first, an expired CRL is still used but the results from it are marked as stale
+	var crls []verifiedCRL
+	for _, path := range crlPaths {
+		block, err := util.TryParsePEM(path)
+		if err != nil {
+			return cli.Exit(fmt.Sprintf("failed to read CRL %s: %v", path, err), 1)
+		}
+		rl, issuer, err := crl.VerifyCRL(block.Bytes, issuers, at)
+		if errors.Is(err, crl.ErrCRLExpired) || errors.Is(err, crl.ErrCRLNotYetValid) {
+			log.Warn().Str("path", path).Msg(err.Error())
+		} else if err != nil {
+			return cli.Exit(fmt.Sprintf("failed to verify CRL %s: %v", path, err), 1)
+		}
+		baseNumber, err := crl.DeltaBaseNumber(rl)
+		if err != nil {
+			return cli.Exit(fmt.Sprintf("failed to read CRL %s: %v", path, err), 1)
+		}
+		crls = append(crls, verifiedCRL{path: path, rl: rl, issuer: issuer, delta: baseNumber != nil})
+	}
+
+	// complete CRLs go before the delta CRLs applied to them, and older CRLs before newer ones. CRLs
+	// without a CRL number cannot be ordered by it, they go first by this-update time.
+	sort.SliceStable(crls, func(i, j int) bool {
+		if crls[i].delta != crls[j].delta {
+			return !crls[i].delta
+		}
+		ni, nj := crls[i].rl.Number, crls[j].rl.Number
+		if ni == nil || nj == nil {
+			if ni == nil && nj == nil {
+				return crls[i].rl.ThisUpdate.Before(crls[j].rl.ThisUpdate)
+			}
+			return ni == nil
+		}
+		return ni.Cmp(nj) < 0
+	})
+
+	checker := crl.NewRevocationChecker(at)
+	for _, v := range crls {
+		if err := checker.AddCRL(v.path, v.rl, v.issuer); err != nil {
+			return cli.Exit(err.Error(), 1)
+		}
+	}
+
+	var results []crl.CheckedCertificate
+	for _, path := range c.StringSlice("certs") {
+		crts, err := util.ReadCertificates(path, "")
+		if err != nil {
+			return cli.Exit(fmt.Sprintf("failed to read certificates to check: %v", err), 1)
+		}
+		for _, crt := range crts {
+			results = append(results, checker.CheckCertificate(crt))
+		}
+	}
+	for _, serial := range c.StringSlice("serial") {
+		normalized, ok := util.NormalizeSerial(strings.NewReplacer(":", "", " ", "").Replace(serial))
+		if !ok {
+			return cli.Exit(fmt.Sprintf("invalid serial number %q", serial), 1)
+		}
+		results = append(results, checker.CheckSerial(normalized))
+	}
+
+	if format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(results)
+	} else {
+		err = writeCheckResults(os.Stdout, results)
+	}
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("failed to write results: %v", err), 1)
+	}
+
+	return nil
+}
+
+// writeCheckResults writes one line per checked certificate.
+func writeCheckResults(w io.Writer, results []crl.CheckedCertificate) error {
+	for _, result := range results {
+		line := result.Serial
+		if result.Subject != nil {
+			line += fmt.Sprintf(" (%s)", *result.Subject)
+		}
+		line += ": " + string(result.Status)
+
+		switch result.Status {
+		case crl.StatusRevoked:
+			line += " at " + result.RevocationTime.Format(time.RFC3339)
+			if result.Reason != nil {
+				line += ", reason " + *result.Reason
+			}
+			if result.InvalidityDate != nil {
+				line += ", invalid since " + result.InvalidityDate.Format(time.RFC3339)
+			}
+			line += fmt.Sprintf(" [%s]", *result.CRL)
+		}
+		if result.Stale {
+			line += ", stale"
+		}
+		if result.Detail != nil {
+			line += ", " + *result.Detail
+		}
+
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
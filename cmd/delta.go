@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/goodieshq/revokr/pkg/crl"
+	"github.com/goodieshq/revokr/pkg/util"
+	"github.com/rs/zerolog/log"
+	"github.com/urfave/cli/v3"
+)
+
+func cmdDelta(_ context.Context, c *cli.Command) error {
+	var serialsRelease []string
+	var err error
+
+	basePath := c.String("base")
+	if basePath == "" {
+		return cli.Exit("base CRL path must be specified with --base/-b", 1)
+	}
+
+	// Check if TBS output is requested
+	tbs := c.Bool("to-be-signed")
+	digestPath := c.String("digest")
+	requestPath := c.String("request")
+	if tbs && digestPath == "" && requestPath == "" {
+		return cli.Exit("target digest or signing request path must be specified when creating a TBS CRL", 1)
+	}
+	if !tbs && requestPath != "" {
+		return cli.Exit("--request can only be used with --to-be-signed/--tbs", 1)
+	}
+
+	digestFormat, err := readDigestFormat(c)
+	if err != nil {
+		return err
+	}
+
+	serialsInclude, revocations, err := readRevocations(c)
+	if err != nil {
+		return err
+	}
+
+	// Read serial numbers of certificates on hold that are released by this delta CRL
+	releasePath := c.String("release")
+	if releasePath != "" {
+		serialsRelease, err = util.ReadSerialNumbersFromFile(releasePath)
+		if err != nil {
+			return cli.Exit(fmt.Sprintf("failed to read release file: %v", err), 1)
+		}
+	}
+
+	crt, key, err := loadIssuer(c, tbs)
+	if err != nil {
+		return err
+	}
+	defer closeKey(key)
+
+	sigAlg, err := readSignatureAlgorithm(c, crt)
+	if err != nil {
+		return err
+	}
+
+	updateThis, updateNext, err := readValidity(c)
+	if err != nil {
+		return err
+	}
+
+	// Read the base CRL using the same parsing as --extend
+	baseNumber, baseEntries, err := crl.ExtractRevocationEntries(nil, basePath)
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("failed to extract revocation entries from base CRL: %v", err), 1)
+	}
+	if baseNumber.Sign() < 0 {
+		return cli.Exit("base CRL could not be read or has no CRL number", 1)
+	}
+
+	// A delta CRL must have the same scope as its base CRL
+	idp, err := crl.ExtractIssuingDistributionPoint(basePath)
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("failed to read issuing distribution point of base CRL: %v", err), 1)
+	}
+
+	// Complete and delta CRLs share one monotonic number sequence (RFC 5280 section 5.2.3). Any
+	// default derived from the base CRL would collide with the next complete CRL, so the number
+	// must be given explicitly.
+	numberStr := c.String("number")
+	if numberStr == "" {
+		return cli.Exit("delta CRL number must be specified with --number/-n", 1)
+	}
+	crlNumber, _ := new(big.Int).SetString(numberStr, 10)
+	if crlNumber.Cmp(baseNumber) <= 0 {
+		return cli.Exit(fmt.Sprintf("delta CRL number %s must be greater than the base CRL number %s", crlNumber, baseNumber), 1)
+	}
+	log.Info().Str("number", crlNumber.String()).Str("base", baseNumber.String()).Msg("Creating delta CRL")
+
+	deltaRevocations, err := crl.DeltaRevocations(crt.RawSubject, baseEntries, revocations, serialsInclude, serialsRelease)
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("failed to determine delta CRL entries: %v", err), 1)
+	}
+
+	err = crl.CreateCRL(crt, key, &crl.CreateCRLParams{
+		Revocations:        deltaRevocations,
+		TBS:                tbs,
+		DigestPath:         digestPath,
+		DigestFormat:       digestFormat,
+		RequestPath:        requestPath,
+		OutPath:            c.String("out"),
+		OutPEM:             c.Bool("pem"),
+		SignatureAlgorithm: sigAlg,
+		CRLNumber:          crlNumber,
+		BaseCRLNumber:      baseNumber,
+		ThisUpdate:         updateThis,
+		NextUpdate:         updateNext,
+
+		IssuingDistributionPoint: idp,
+	})
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("failed to create delta CRL: %v", err), 1)
+	}
+
+	return nil
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/goodieshq/revokr/pkg/util"
+	"github.com/urfave/cli/v3"
+)
+
+// Flag sets shared between the commands that produce CRLs.
+
+// numberFlag describes what the CRL number defaults to when not specified.
+func numberFlag(defaultUsage string) cli.Flag {
+	return &cli.StringFlag{
+		Name:    "number",
+		Usage:   "CRL number to use (in decimel). " + defaultUsage,
+		Aliases: []string{"n"},
+		Value:   "",
+		Validator: func(s string) error {
+			if _, ok := new(big.Int).SetString(s, 10); !ok {
+				return cli.Exit("invalid CRL number, must be a valid decimal number", 1)
+			}
+			return nil
+		},
+	}
+}
+
+// signatureAlgorithmFlag describes what the signature algorithm defaults to when not specified.
+func signatureAlgorithmFlag(defaultUsage string) cli.Flag {
+	return &cli.StringFlag{
+		Name:  "sig-alg",
+		Usage: "Signature algorithm to sign the CRL with (e.g. SHA256-RSA, ECDSA-SHA384). If not specified, " + defaultUsage,
+	}
+}
+
+func revocationFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:    "serials",
+			Aliases: []string{"s"},
+			Usage:   "file containing list of serial numbers (in hexadecimal) to include in the CRL",
+		},
+		&cli.StringFlag{
+			Name:    "manifest",
+			Aliases: []string{"m"},
+			Usage:   "CSV or JSON revocation manifest with a serial, reason, revocation_time, invalidity_date and comment per entry",
+		},
+	}
+}
+
+// keyFileFlags are the flags for an issuer private key file and its password.
+func keyFileFlags() []cli.Flag {
+	return append([]cli.Flag{
+		&cli.StringFlag{
+			Name:    "key",
+			Aliases: []string{"k"},
+			Usage:   "Path to the issuing certificate private key file.",
+		},
+	}, passwordFlags()...)
+}
+
+// passwordFlags are the flags for the password of an encrypted private key or PKCS#12 bundle.
+func passwordFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:    "password",
+			Aliases: []string{"p"},
+			Usage:   "Password for the issuing certificate private key, if it is encrypted.",
+		},
+		&cli.BoolFlag{
+			Name:    "password-prompt",
+			Usage:   "Prompt for the password for the issuing certificate private key, if it is encrypted. (overrides --password/-p)",
+			Aliases: []string{"P"},
+		},
+		&cli.StringFlag{
+			Name:  "password-source",
+			Usage: "Read the private key password from env:<var>, file:<path>, fd:<n>, cmd:<command>, pinentry:<program>, shamir or prompt (overrides --password/-p)",
+		},
+	}
+}
+
+func issuerKeyFlags() []cli.Flag {
+	return append(keyFileFlags(),
+		&cli.StringFlag{
+			Name:  "signer",
+			Usage: "External signer to use instead of --key/-k: exec:/path/to/plugin, vault:[<address>/]<mount>/<key> or awskms:<key-id>",
+		},
+		&cli.StringFlag{
+			Name:  "pkcs11-module",
+			Usage: "Path to a PKCS#11 module to sign with a key on a token or HSM instead of --key/-k",
+		},
+		&cli.StringFlag{
+			Name:  "pkcs11-pin-source",
+			Usage: "Read the PKCS#11 token PIN from env:<var>, file:<path>, fd:<n>, cmd:<command>, pinentry:<program>, shamir or prompt",
+			Value: "prompt",
+		},
+		&cli.StringFlag{
+			Name:  "pkcs11-token",
+			Usage: "Label of the PKCS#11 token holding the issuer key (optional if only one token is present)",
+		},
+		&cli.StringFlag{
+			Name:  "pkcs11-key-label",
+			Usage: "Label (CKA_LABEL) of the issuer key on the PKCS#11 token",
+		},
+		&cli.StringFlag{
+			Name:  "pkcs11-key-id",
+			Usage: "Hex encoded ID (CKA_ID) of the issuer key on the PKCS#11 token",
+		},
+	)
+}
+
+func validityFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:    "this-update",
+			Aliases: []string{"tu", "T"},
+			Usage:   "Set the 'this update' time for the CRL (RFC3339 format). If not specified, uses the NotBefore time of the issuing certificate.",
+			Validator: func(s string) error {
+				_, err := util.ParseTime(s)
+				if err != nil {
+					return cli.Exit(fmt.Sprintf("invalid time format for --this-update/-t: %v", err), 1)
+				}
+				return nil
+			},
+		},
+		&cli.StringFlag{
+			Name:    "next-update",
+			Aliases: []string{"nu", "N"},
+			Usage:   "Set the 'next update' time for the CRL (RFC3339 format). If not specified, uses the NotAfter time of the issuing certificate",
+			Validator: func(s string) error {
+				_, err := util.ParseTime(s)
+				if err != nil {
+					return cli.Exit(fmt.Sprintf("invalid time format for --next-update/-n: %v", err), 1)
+				}
+				return nil
+			},
+		},
+	}
+}
+
+func atFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:  "at",
+		Usage: "Check the validity window of the CRLs at this time (RFC3339 format). If not specified, uses the system time.",
+		Validator: func(s string) error {
+			_, err := util.ParseTime(s)
+			if err != nil {
+				return cli.Exit(fmt.Sprintf("invalid time format for --at: %v", err), 1)
+			}
+			return nil
+		},
+	}
+}
+
+func tbsFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.BoolFlag{
+			Name:    "to-be-signed",
+			Aliases: []string{"tbs", "t"},
+			Usage:   "Output the 'to be signed' portion of the CRL in PEM format to stdout instead of creating a signed CRL.",
+		},
+		&cli.StringFlag{
+			Name:    "digest",
+			Aliases: []string{"d"},
+			Usage:   "Target file to output the digest of the TBS CRL to when using --to-be-signed/--tbs. A JSON description of the hash and padding is written next to it.",
+		},
+		&cli.StringFlag{
+			Name:  "digest-format",
+			Usage: "Format of the TBS CRL digest: raw, hex, base64 or digestinfo (DER PKCS#1 DigestInfo for RSA PKCS#1 v1.5 signers)",
+			Value: string(util.DigestFormatBase64),
+			Validator: func(s string) error {
+				if _, err := util.ParseDigestFormat(s); err != nil {
+					return cli.Exit(fmt.Sprintf("invalid --digest-format: %v", err), 1)
+				}
+				return nil
+			},
+		},
+		&cli.StringFlag{
+			Name:  "request",
+			Usage: "Target file to write a signing request to when using --to-be-signed/--tbs. The request carries the TBS CRL, the issuer certificate and a description of what has to be signed.",
+		},
+	}
+}
+
+func idpFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:  "idp-uri",
+			Usage: "distribution point URI of the issuing distribution point extension (may be repeated)",
+		},
+		&cli.BoolFlag{
+			Name:  "idp-only-user",
+			Usage: "set onlyContainsUserCerts in the issuing distribution point extension",
+		},
+		&cli.BoolFlag{
+			Name:  "idp-only-ca",
+			Usage: "set onlyContainsCACerts in the issuing distribution point extension",
+		},
+		&cli.StringSliceFlag{
+			Name:  "idp-reasons",
+			Usage: "revocation reason covered by this CRL, sets onlySomeReasons in the issuing distribution point extension (may be repeated)",
+		},
+		&cli.BoolFlag{
+			Name:  "idp-indirect",
+			Usage: "set indirectCRL in the issuing distribution point extension",
+		},
+	}
+}
+
+// flags concatenates flag sets into a single list.
+func flags(sets ...[]cli.Flag) []cli.Flag {
+	var all []cli.Flag
+	for _, set := range sets {
+		all = append(all, set...)
+	}
+	return all
+}
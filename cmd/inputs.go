@@ -0,0 +1,408 @@
+package main
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/goodieshq/revokr/pkg/crl"
+	"github.com/goodieshq/revokr/pkg/signer"
+	"github.com/goodieshq/revokr/pkg/util"
+	"github.com/rs/zerolog/log"
+	"github.com/urfave/cli/v3"
+)
+
+// loadIssuer parses the issuer certificate and, unless a TBS CRL is requested, the matching private key.
+func loadIssuer(c *cli.Command, tbs bool) (*x509.Certificate, crypto.Signer, error) {
+	var err error
+
+	// Parse issuer certificate and private key
+	issuerCrtPath := c.String("crt")
+	issuerKeyPath := c.String("key")
+	keyGiven := issuerKeyPath != "" || c.String("pkcs11-module") != "" || c.String("signer") != ""
+
+	if tbs && keyGiven {
+		return nil, nil, cli.Exit("issuer private key should not be specified when creating a TBS CRL", 1)
+	}
+
+	if issuerCrtPath == "" {
+		return nil, nil, cli.Exit("issuer certificate path must be specified with --crt/-c", 1)
+	}
+
+	if !tbs && !keyGiven {
+		return nil, nil, cli.Exit("issuer private key must be specified with --key/-k, --pkcs11-module or --signer", 1)
+	}
+
+	// A PKCS#12 certificate bundle may need the password even when not signing
+	passwordGiven := c.String("password") != "" || c.Bool("password-prompt") || c.String("password-source") != ""
+	if tbs && passwordGiven && !util.IsPKCS12File(issuerCrtPath) {
+		return nil, nil, cli.Exit("password should not be specified when creating a TBS CRL", 1)
+	}
+
+	password, err := readPassword(c)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	crts, err := util.ReadCertificates(issuerCrtPath, password)
+	if err != nil {
+		return nil, nil, cli.Exit(fmt.Sprintf("failed to parse issuer certificate: %v", err), 1)
+	}
+
+	if tbs {
+		// no key needed when not signing, the certificate is selected with --crt-subject and --crt-ski
+		crt, err := selectIssuerCrt(c, crts, util.CertificateSelector{})
+		if err != nil {
+			return nil, nil, err
+		}
+		return crt, nil, nil
+	}
+
+	key, err := loadKey(c, password)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Pick the certificate whose public key matches the private key
+	crt, err := selectIssuerCrt(c, crts, util.CertificateSelector{Key: key})
+	if err != nil {
+		closeKey(key)
+		return nil, nil, err
+	}
+
+	return crt, key, nil
+}
+
+// selectIssuerCrt picks the issuer certificate out of the certificates read from --crt, narrowing sel with
+// --crt-subject and --crt-ski, and logs the choice.
+func selectIssuerCrt(c *cli.Command, crts []*x509.Certificate, sel util.CertificateSelector) (*x509.Certificate, error) {
+	if subject := c.String("crt-subject"); subject != "" {
+		sel.Subject = subject
+	}
+	if ski := c.String("crt-ski"); ski != "" {
+		value, err := hex.DecodeString(strings.ReplaceAll(ski, ":", ""))
+		if err != nil {
+			return nil, cli.Exit(fmt.Sprintf("invalid --crt-ski: %v", err), 1)
+		}
+		sel.SKI = value
+	}
+
+	crt, err := util.SelectCertificate(crts, sel)
+	if err != nil {
+		if len(crts) == 1 && sel.Key != nil {
+			if err := util.VerifyCrtKeyMatch(crts[0], sel.Key); err != nil {
+				return nil, cli.Exit(fmt.Sprintf("issuer certificate and private key do not match: %v", err), 1)
+			}
+		}
+		msg := fmt.Sprintf("failed to select issuer certificate from %s: %v", c.String("crt"), err)
+		if sel.Key != nil && errors.Is(err, util.ErrNoMatchingCertificate) {
+			msg += " for the issuer private key"
+		} else if len(crts) > 1 {
+			msg += "; use --crt-subject or --crt-ski to select it"
+		}
+		return nil, cli.Exit(msg, 1)
+	}
+
+	log.Info().Str("subject", crt.Subject.String()).Str("fingerprint", util.Fingerprint(crt)).Msg("Using issuer certificate")
+	return crt, nil
+}
+
+// readPassword returns the private key password given with --password/-p, or reads it from the
+// --password-source or, with --password-prompt/-P, the TTY.
+func readPassword(c *cli.Command) (string, error) {
+	source := c.String("password-source")
+	if c.Bool("password-prompt") {
+		if source != "" {
+			return "", cli.Exit("--password-prompt/-P and --password-source cannot be used together", 1)
+		}
+		source = "prompt"
+	}
+	if source == "" {
+		return c.String("password"), nil
+	}
+
+	password, err := util.ReadPassword(source, "Enter the private key password")
+	if err != nil {
+		return "", cli.Exit(fmt.Sprintf("failed to read private key password: %v", err), 1)
+	}
+	return password, nil
+}
+
+// loadKey parses the issuer private key given with --key/-k using the password from readPassword, or
+// opens the PKCS#11 key given with --pkcs11-module or the external signer given with --signer.
+// Signers opened here are released by closeKey.
+func loadKey(c *cli.Command, password string) (crypto.Signer, error) {
+	issuerKeyPath := c.String("key")
+	pkcs11Module := c.String("pkcs11-module")
+	signerSpec := c.String("signer")
+
+	sources := 0
+	for _, source := range []string{issuerKeyPath, pkcs11Module, signerSpec} {
+		if source != "" {
+			sources++
+		}
+	}
+	if sources > 1 {
+		return nil, cli.Exit("only one of --key/-k, --pkcs11-module and --signer can be used", 1)
+	}
+
+	switch {
+	case pkcs11Module != "":
+		return loadPKCS11Key(c)
+	case signerSpec != "":
+		key, err := signer.Open(signerSpec)
+		if err != nil {
+			return nil, cli.Exit(fmt.Sprintf("failed to open signer: %v", err), 1)
+		}
+		return key, nil
+	case issuerKeyPath == "":
+		return nil, cli.Exit("issuer private key must be specified with --key/-k, --pkcs11-module or --signer", 1)
+	}
+
+	key, err := util.ParsePrivateSigner(issuerKeyPath, password)
+	if err != nil {
+		return nil, cli.Exit(fmt.Sprintf("failed to parse issuer private key: %v", err), 1)
+	}
+
+	if key == nil {
+		return nil, cli.Exit("issuer private key could not be parsed", 1)
+	}
+
+	return key, nil
+}
+
+// loadPKCS11Key opens the issuer key on a PKCS#11 token, reading the token PIN from --pkcs11-pin-source.
+func loadPKCS11Key(c *cli.Command) (crypto.Signer, error) {
+	keyID, err := hex.DecodeString(strings.ReplaceAll(c.String("pkcs11-key-id"), ":", ""))
+	if err != nil {
+		return nil, cli.Exit(fmt.Sprintf("invalid --pkcs11-key-id: %v", err), 1)
+	}
+
+	pin, err := util.ReadPassword(c.String("pkcs11-pin-source"), "Enter the PKCS#11 token PIN")
+	if err != nil {
+		return nil, cli.Exit(fmt.Sprintf("failed to read PKCS#11 PIN: %v", err), 1)
+	}
+
+	key, err := signer.OpenPKCS11(signer.PKCS11Config{
+		Module:     c.String("pkcs11-module"),
+		TokenLabel: c.String("pkcs11-token"),
+		KeyLabel:   c.String("pkcs11-key-label"),
+		KeyID:      keyID,
+		PIN:        pin,
+	})
+	if err != nil {
+		return nil, cli.Exit(fmt.Sprintf("failed to open PKCS#11 key: %v", err), 1)
+	}
+
+	return key, nil
+}
+
+// closeKey releases a signer that holds a session, such as a PKCS#11 key.
+func closeKey(key crypto.Signer) {
+	if closer, ok := key.(io.Closer); ok {
+		closer.Close()
+	}
+}
+
+// readRevocations reads the serials file and revocation manifest given on the command line.
+func readRevocations(c *cli.Command) ([]string, []util.RevocationRecord, error) {
+	var serials []string
+	var revocations []util.RevocationRecord
+	var err error
+
+	// Read serial numbers of certificates to include in the CRL
+	serialsPath := c.String("serials")
+	if serialsPath != "" {
+		serials, err = util.ReadSerialNumbersFromFile(serialsPath)
+		if err != nil {
+			return nil, nil, cli.Exit(fmt.Sprintf("failed to read serials file: %v", err), 1)
+		}
+	}
+
+	// Read revocation entries with per-entry reasons and dates from the manifest
+	manifestPath := c.String("manifest")
+	if manifestPath != "" {
+		revocations, err = util.ReadRevocationManifest(manifestPath)
+		if err != nil {
+			return nil, nil, cli.Exit(fmt.Sprintf("failed to read revocation manifest: %v", err), 1)
+		}
+	}
+
+	return serials, revocations, nil
+}
+
+// readValidity parses the this-update and next-update times given on the command line.
+func readValidity(c *cli.Command) (time.Time, time.Time, error) {
+	thisUpdate, err := util.ParseTime(c.String("this-update"))
+	if err != nil {
+		return time.Time{}, time.Time{}, cli.Exit(fmt.Sprintf("failed to parse this-update time: %v", err), 1)
+	}
+
+	nextUpdate, err := util.ParseTime(c.String("next-update"))
+	if err != nil {
+		return time.Time{}, time.Time{}, cli.Exit(fmt.Sprintf("failed to parse next-update time: %v", err), 1)
+	}
+
+	return thisUpdate, nextUpdate, nil
+}
+
+// readAt parses the --at time used to check the validity window of CRLs, defaulting to the system time.
+func readAt(c *cli.Command) (time.Time, error) {
+	if c.String("at") == "" {
+		return time.Now(), nil
+	}
+	at, err := util.ParseTime(c.String("at"))
+	if err != nil {
+		return time.Time{}, cli.Exit(fmt.Sprintf("failed to parse --at time: %v", err), 1)
+	}
+	return at, nil
+}
+
+// readIssuingDistributionPoint carries forward the issuing distribution point of the given CRLs and
+// applies any --idp-* flags on top of it. Returns nil if the CRL should have no such extension.
+func readIssuingDistributionPoint(c *cli.Command, paths ...string) (*crl.IssuingDistributionPoint, error) {
+	idp, err := crl.ExtractIssuingDistributionPoint(paths...)
+	if err != nil {
+		return nil, cli.Exit(fmt.Sprintf("failed to read issuing distribution point: %v", err), 1)
+	}
+
+	uris := c.StringSlice("idp-uri")
+	reasons := c.StringSlice("idp-reasons")
+	onlyUser, onlyCA, indirect := c.Bool("idp-only-user"), c.Bool("idp-only-ca"), c.Bool("idp-indirect")
+
+	if len(uris) == 0 && len(reasons) == 0 && !onlyUser && !onlyCA && !indirect {
+		return idp, nil
+	}
+
+	if idp == nil {
+		idp = &crl.IssuingDistributionPoint{}
+	}
+
+	if len(uris) > 0 {
+		idp.URIs = uris
+	}
+
+	if len(reasons) > 0 {
+		idp.OnlySomeReasons = nil
+		for _, r := range reasons {
+			reason, err := util.ParseReason(r)
+			if err != nil {
+				return nil, cli.Exit(fmt.Sprintf("invalid --idp-reasons value: %v", err), 1)
+			}
+			idp.OnlySomeReasons = append(idp.OnlySomeReasons, reason)
+		}
+	}
+
+	if onlyUser {
+		idp.OnlyContainsUserCerts, idp.OnlyContainsCACerts = true, false
+	}
+	if onlyCA {
+		idp.OnlyContainsCACerts, idp.OnlyContainsUserCerts = true, false
+	}
+	if onlyUser && onlyCA {
+		return nil, cli.Exit("--idp-only-user and --idp-only-ca are mutually exclusive", 1)
+	}
+	if indirect {
+		idp.IndirectCRL = true
+	}
+
+	if err := idp.Validate(); err != nil {
+		return nil, cli.Exit(fmt.Sprintf("invalid issuing distribution point: %v", err), 1)
+	}
+
+	return idp, nil
+}
+
+// readSignatureAlgorithm returns the --sig-alg signature algorithm validated against the issuer's
+// public key, or the default algorithm for that key.
+func readSignatureAlgorithm(c *cli.Command, crt *x509.Certificate) (x509.SignatureAlgorithm, error) {
+	alg, err := util.SignatureAlgorithmForKey(crt.PublicKey, c.String("sig-alg"))
+	if err != nil {
+		return x509.UnknownSignatureAlgorithm, cli.Exit(fmt.Sprintf("invalid signature algorithm: %v", err), 1)
+	}
+	return alg, nil
+}
+
+// readDigestFormat returns the --digest-format used for the TBS CRL digest.
+func readDigestFormat(c *cli.Command) (util.DigestFormat, error) {
+	format, err := util.ParseDigestFormat(c.String("digest-format"))
+	if err != nil {
+		return "", cli.Exit(fmt.Sprintf("invalid --digest-format: %v", err), 1)
+	}
+	return format, nil
+}
+
+// readTBSInputs reads the TBS CRL and issuer certificate, either from a signing request or from
+// --to-be-signed/--tbs and --crt. The signing request is nil when a plain TBS CRL is used. The issuer
+// certificate is only optional for a plain TBS CRL when crtOptional is set, and password is used to
+// open a PKCS#12 certificate bundle.
+func readTBSInputs(c *cli.Command, crtOptional bool, password string) (*asn1.RawValue, *x509.Certificate, *crl.SigningRequest, error) {
+	tbsPath := c.String("to-be-signed")
+	requestPath := c.String("request")
+	issuerCrtPath := c.String("crt")
+
+	if tbsPath != "" && requestPath != "" {
+		return nil, nil, nil, cli.Exit("--to-be-signed/-t and --request cannot be used together", 1)
+	}
+
+	if requestPath != "" {
+		req, err := crl.ReadSigningRequest(requestPath)
+		if err != nil {
+			return nil, nil, nil, cli.Exit(fmt.Sprintf("failed to read signing request: %v", err), 1)
+		}
+
+		if issuerCrtPath != "" {
+			crts, err := util.ReadCertificates(issuerCrtPath, password)
+			if err != nil {
+				return nil, nil, nil, cli.Exit(fmt.Sprintf("failed to parse issuer certificate: %v", err), 1)
+			}
+			crt, err := selectIssuerCrt(c, crts, util.CertificateSelector{RawSubject: req.Issuer.RawSubject})
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			if err := req.CheckIssuer(crt); err != nil {
+				return nil, nil, nil, cli.Exit(err.Error(), 1)
+			}
+		}
+
+		log.Info().Msgf("Read signing request for %s", req)
+		return &asn1.RawValue{FullBytes: req.TBS}, req.Issuer, req, nil
+	}
+
+	if tbsPath == "" {
+		return nil, nil, nil, cli.Exit("TBS CRL path must be specified with --to-be-signed/-t or --request", 1)
+	}
+	tbs, err := util.ParseTBSCRL(tbsPath)
+	if err != nil {
+		return nil, nil, nil, cli.Exit(fmt.Sprintf("failed to parse TBS CRL: %v", err), 1)
+	}
+
+	if issuerCrtPath == "" {
+		if crtOptional {
+			return tbs, nil, nil, nil
+		}
+		return nil, nil, nil, cli.Exit("issuer certificate path must be specified with --crt/-c", 1)
+	}
+	crts, err := util.ReadCertificates(issuerCrtPath, password)
+	if err != nil {
+		return nil, nil, nil, cli.Exit(fmt.Sprintf("failed to parse issuer certificate: %v", err), 1)
+	}
+
+	// Candidates are narrowed down to the issuer named in the TBS CRL and its authority key identifier
+	rl, err := util.ParseTBS(tbs.FullBytes)
+	if err != nil {
+		return nil, nil, nil, cli.Exit(fmt.Sprintf("failed to parse TBS CRL: %v", err), 1)
+	}
+	crt, err := selectIssuerCrt(c, crts, util.CertificateSelector{RawSubject: rl.RawIssuer, SKI: rl.AuthorityKeyId})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return tbs, crt, nil, nil
+}
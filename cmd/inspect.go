@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/goodieshq/revokr/pkg/crl"
+	"github.com/urfave/cli/v3"
+)
+
+func cmdInspect(_ context.Context, c *cli.Command) error {
+	if c.Args().Len() != 1 {
+		return cli.Exit("inspect takes exactly one CRL, TBS CRL or signing request file", 1)
+	}
+	path := c.Args().First()
+
+	in, err := crl.InspectFile(path)
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("failed to inspect %s: %v", path, err), 1)
+	}
+
+	switch c.String("format") {
+	case "text":
+		err = in.WriteText(os.Stdout)
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(in)
+	default:
+		return cli.Exit(fmt.Sprintf("invalid --format %q, expected text or json", c.String("format")), 1)
+	}
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("failed to write inspection: %v", err), 1)
+	}
+
+	return nil
+}
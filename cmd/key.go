@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/goodieshq/revokr/pkg/util"
+	"github.com/rs/zerolog/log"
+	"github.com/urfave/cli/v3"
+)
+
+func cmdKeyRewrap(_ context.Context, c *cli.Command) error {
+	keyPath := c.String("key")
+	if keyPath == "" {
+		return cli.Exit("private key path must be specified with --key/-k", 1)
+	}
+	if c.String("crt") == "" {
+		return cli.Exit("issuer certificate path must be specified with --crt/-c", 1)
+	}
+
+	outPath := c.String("out")
+	if outPath == "" {
+		if util.IsPKCS12File(keyPath) {
+			return cli.Exit("a PKCS#12 bundle cannot be replaced by a PKCS#8 key, write it elsewhere with --out/-o", 1)
+		}
+		outPath = keyPath
+	}
+
+	block, err := util.TryParsePEM(keyPath)
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("failed to read private key: %v", err), 1)
+	}
+	log.Info().Str("path", keyPath).Msgf("Private key is protected with %s", util.DescribeKeyEncryption(block))
+
+	password, err := readPassword(c)
+	if err != nil {
+		return err
+	}
+
+	key, err := util.ParsePrivateSigner(keyPath, password)
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("failed to parse private key: %v", err), 1)
+	}
+
+	crts, err := util.ReadCertificates(c.String("crt"), password)
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("failed to parse issuer certificate: %v", err), 1)
+	}
+	crt, err := selectIssuerCrt(c, crts, util.CertificateSelector{Key: key})
+	if err != nil {
+		return err
+	}
+
+	newSource := c.String("new-password-source")
+	newPassword, err := util.ReadPassword(newSource, "Enter the new private key password")
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("failed to read new private key password: %v", err), 1)
+	}
+	if newPassword == "" {
+		return cli.Exit("new private key password is empty", 1)
+	}
+	if newSource == "prompt" {
+		again, err := util.PromptPassword("Enter the new private key password again")
+		if err != nil {
+			return cli.Exit(fmt.Sprintf("failed to read new private key password: %v", err), 1)
+		}
+		if again != newPassword {
+			return cli.Exit("new private key passwords do not match", 1)
+		}
+	}
+
+	opts := util.KeyEncryptionOptions{
+		KDF:              c.String("kdf"),
+		Cipher:           c.String("cipher"),
+		ScryptN:          int(c.Int("scrypt-n")),
+		ScryptR:          int(c.Int("scrypt-r")),
+		ScryptP:          int(c.Int("scrypt-p")),
+		PBKDF2Iterations: int(c.Int("pbkdf2-iterations")),
+	}
+	data, err := util.MarshalEncryptedPKCS8(key, newPassword, opts)
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("failed to rewrap private key: %v", err), 1)
+	}
+
+	// write next to the target, read the result back with the new password and only then replace the target
+	tmp, err := os.CreateTemp(filepath.Dir(outPath), "."+filepath.Base(outPath)+".*")
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("failed to create temporary key file: %v", err), 1)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("failed to write rewrapped private key: %v", err), 1)
+	}
+
+	rewrapped, err := util.ParsePrivateSigner(tmp.Name(), newPassword)
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("failed to read back rewrapped private key: %v", err), 1)
+	}
+	if err := util.VerifyCrtKeyMatch(crt, rewrapped); err != nil {
+		return cli.Exit(fmt.Sprintf("rewrapped private key does not match the issuer certificate: %v", err), 1)
+	}
+
+	if err := os.Rename(tmp.Name(), outPath); err != nil {
+		return cli.Exit(fmt.Sprintf("failed to replace private key: %v", err), 1)
+	}
+
+	written, _ := util.TryParsePEM(outPath)
+	log.Info().Str("path", outPath).Msgf("Rewrapped private key with %s", util.DescribeKeyEncryption(written))
+	return nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/goodieshq/revokr/pkg/crl"
+	"github.com/rs/zerolog/log"
+	"github.com/urfave/cli/v3"
+)
+
+func cmdLint(_ context.Context, c *cli.Command) error {
+	if c.Args().Len() != 1 {
+		return cli.Exit("lint takes exactly one CRL, TBS CRL or signing request file", 1)
+	}
+	path := c.Args().First()
+
+	format := c.String("format")
+	if format != "text" && format != "json" {
+		return cli.Exit(fmt.Sprintf("invalid --format %q, expected text or json", format), 1)
+	}
+
+	findings, err := crl.LintFile(path)
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("failed to lint %s: %v", path, err), 1)
+	}
+
+	if format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if findings == nil {
+			findings = []crl.LintFinding{}
+		}
+		err = enc.Encode(findings)
+	} else {
+		for _, finding := range findings {
+			if _, err = fmt.Printf("%-7s %s: %s\n", finding.Severity, finding.Check, finding.Message); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("failed to write findings: %v", err), 1)
+	}
+
+	errors := 0
+	for _, finding := range findings {
+		if finding.Severity == crl.LintError {
+			errors++
+		}
+	}
+	if errors > 0 {
+		return cli.Exit(fmt.Sprintf("%s fails %d lint checks", path, errors), 1)
+	}
+
+	log.Info().Str("path", path).Msgf("CRL passes all lint checks with %d findings", len(findings))
+	return nil
+}
@@ -2,7 +2,7 @@ package main
 
 import (
 	"context"
-	"crypto"
+	"crypto/x509"
 	"fmt"
 	"math/big"
 	"os"
@@ -38,101 +38,291 @@ func init() {
 				Action: func(ctx context.Context, c *cli.Command) error {
 					return cmdCreate(ctx, c)
 				},
-				Flags: []cli.Flag{
-					&cli.StringFlag{
-						Name:    "number",
-						Usage:   "CRL number to use (in decimel). If not specified, defaults to 1 or increments the highest CRL number found in any extended CRLs.",
-						Aliases: []string{"n"},
-						Value:   "",
-						Validator: func(s string) error {
-							if _, ok := new(big.Int).SetString(s, 10); !ok {
-								return cli.Exit("invalid CRL number, must be a valid decimal number", 1)
-							}
-							return nil
+				Flags: flags(
+					[]cli.Flag{
+						numberFlag("If not specified, defaults to 1 or increments the highest CRL number found in any extended CRLs."),
+						signatureAlgorithmFlag("it is chosen from the issuer's public key."),
+						&cli.StringSliceFlag{
+							Name:    "extend",
+							Aliases: []string{"x"},
+							Usage:   "Path to existing CRL to copy and extend. The new CRL inherets all revoked serials except those in the ignore list.",
 						},
 					},
-					&cli.StringSliceFlag{
-						Name:    "extend",
-						Aliases: []string{"x"},
-						Usage:   "Path to existing CRL to copy and extend. The new CRL inherets all revoked serials except those in the ignore list.",
+					issuerKeyFlags(),
+					revocationFlags(),
+					[]cli.Flag{
+						&cli.StringFlag{
+							Name:    "ignore",
+							Aliases: []string{"i"},
+							Usage:   "file containing list of serial numbers (in hexadecimal) to ignore when creating the CRL",
+						},
+						&cli.StringSliceFlag{
+							Name:  "freshest-crl",
+							Usage: "URI where delta CRLs for this CRL are published, added as a freshest CRL extension (may be repeated)",
+						},
 					},
-					&cli.StringFlag{
-						Name:    "key",
-						Aliases: []string{"k"},
-						Usage:   "Path to the issuing certificate private key file.",
+					idpFlags(),
+					[]cli.Flag{
+						&cli.BoolFlag{
+							Name:  "arl",
+							Usage: "Create an authority revocation list (sets onlyContainsCACerts in the issuing distribution point extension)",
+						},
+						&cli.StringFlag{
+							Name:  "certs",
+							Usage: "Directory of certificates issued by the CA, used with --arl to refuse serials of end-entity certificates",
+						},
 					},
-					&cli.StringFlag{
-						Name:    "password",
-						Aliases: []string{"p"},
-						Usage:   "Password for the issuing certificate private key, if it is encrypted.",
+					validityFlags(),
+					tbsFlags(),
+				),
+			},
+			{
+				Name:  "delta",
+				Usage: "Create a delta CRL containing the changes since a base CRL",
+				Action: func(ctx context.Context, c *cli.Command) error {
+					return cmdDelta(ctx, c)
+				},
+				Flags: flags(
+					[]cli.Flag{
+						&cli.StringFlag{
+							Name:    "base",
+							Aliases: []string{"b"},
+							Usage:   "Path to the base CRL the delta CRL is issued against.",
+						},
+						numberFlag("Required, and must be greater than the base CRL number, since complete and delta CRLs share one number sequence."),
+						signatureAlgorithmFlag("it is chosen from the issuer's public key."),
 					},
-					&cli.BoolFlag{
-						Name:    "password-prompt",
-						Usage:   "Prompt for the password for the issuing certificate private key, if it is encrypted. (overrides --password/-p)",
-						Aliases: []string{"P"},
+					issuerKeyFlags(),
+					revocationFlags(),
+					[]cli.Flag{
+						&cli.StringFlag{
+							Name:    "release",
+							Aliases: []string{"r"},
+							Usage:   "file containing list of serial numbers (in hexadecimal) on hold in the base CRL to release with removeFromCRL",
+						},
 					},
-					&cli.StringFlag{
-						Name:    "serials",
-						Aliases: []string{"s"},
-						Usage:   "file containing list of serial numbers (in hexadecimal) to include in the CRL",
+					validityFlags(),
+					tbsFlags(),
+				),
+			},
+			{
+				Name:  "assemble",
+				Usage: "Assemble a CRL from a Cert, TBS CRL, and a signature from the issuing CA.",
+				Action: func(ctx context.Context, c *cli.Command) error {
+					return cmdAssemble(ctx, c)
+				},
+				Flags: flags(
+					[]cli.Flag{
+						&cli.StringFlag{
+							Name:    "to-be-signed",
+							Aliases: []string{"tbs", "t"},
+							Usage:   "The TBS CRL file to use for assembling the final CRL.",
+						},
+						&cli.StringFlag{
+							Name:  "request",
+							Usage: "The signing request to use for assembling the final CRL, instead of --to-be-signed/--tbs. --crt is optional and must match the issuer of the request.",
+						},
+						&cli.StringFlag{
+							Name:    "signature",
+							Aliases: []string{"s"},
+							Usage:   "The signature file to use for assembling the final CRL.",
+						},
+						&cli.StringFlag{
+							Name:  "signature-format",
+							Usage: "Format of the signature file: auto, der (binary DER ECDSA-Sig-Value), raw (binary r||s for ECDSA), hex or base64",
+							Value: string(util.SignatureFormatAuto),
+							Validator: func(s string) error {
+								if _, err := util.ParseSignatureFormat(s); err != nil {
+									return cli.Exit(fmt.Sprintf("invalid --signature-format: %v", err), 1)
+								}
+								return nil
+							},
+						},
+						signatureAlgorithmFlag("the algorithm of the TBS CRL is used."),
 					},
-					&cli.StringFlag{
-						Name:    "ignore",
-						Aliases: []string{"i"},
-						Usage:   "file containing list of serial numbers (in hexadecimal) to ignore when creating the CRL",
+					passwordFlags(),
+				),
+			},
+			{
+				Name:  "sign",
+				Usage: "Sign a TBS CRL or signing request with the issuer private key on an offline signing station.",
+				Action: func(ctx context.Context, c *cli.Command) error {
+					return cmdSign(ctx, c)
+				},
+				Flags: flags(
+					[]cli.Flag{
+						&cli.StringFlag{
+							Name:    "to-be-signed",
+							Aliases: []string{"tbs", "t"},
+							Usage:   "The TBS CRL file to sign. --crt is optional and used to check the key and the signature.",
+						},
+						&cli.StringFlag{
+							Name:  "request",
+							Usage: "The signing request to sign, instead of --to-be-signed/--tbs.",
+						},
+						&cli.StringFlag{
+							Name:    "signature",
+							Aliases: []string{"s"},
+							Usage:   "Target file to write the signature to, for use with assemble.",
+						},
+						&cli.BoolFlag{
+							Name:    "yes",
+							Aliases: []string{"y"},
+							Usage:   "Sign without asking for confirmation.",
+						},
 					},
+					issuerKeyFlags(),
+				),
+			},
+			{
+				Name:      "inspect",
+				Usage:     "Print the contents of a CRL, TBS CRL or signing request, with all extensions decoded.",
+				ArgsUsage: "<file>",
+				Action: func(ctx context.Context, c *cli.Command) error {
+					return cmdInspect(ctx, c)
+				},
+				Flags: []cli.Flag{
 					&cli.StringFlag{
-						Name:    "this-update",
-						Aliases: []string{"tu", "T"},
-						Usage:   "Set the 'this update' time for the CRL (RFC3339 format). If not specified, uses the NotBefore time of the issuing certificate.",
-						Validator: func(s string) error {
-							_, err := util.ParseTime(s)
-							if err != nil {
-								return cli.Exit(fmt.Sprintf("invalid time format for --this-update/-t: %v", err), 1)
-							}
-							return nil
-						},
+						Name:    "format",
+						Aliases: []string{"f"},
+						Usage:   "Output format: text (similar to openssl crl -text) or json",
+						Value:   "text",
 					},
+				},
+			},
+			{
+				Name:      "verify",
+				Usage:     "Verify a CRL against the issuer certificate or chain given with --crt. Exits with 2 if the CRL is invalid, 3 if it has expired and 4 if it is not yet valid.",
+				ArgsUsage: "<crl>",
+				Action: func(ctx context.Context, c *cli.Command) error {
+					return cmdVerify(ctx, c)
+				},
+				Flags: []cli.Flag{
+					atFlag(),
+				},
+			},
+			{
+				Name:      "lint",
+				Usage:     "Check a CRL, TBS CRL or signing request for RFC 5280 conformance. Exits with 1 if any check fails with an error.",
+				ArgsUsage: "<file>",
+				Action: func(ctx context.Context, c *cli.Command) error {
+					return cmdLint(ctx, c)
+				},
+				Flags: []cli.Flag{
 					&cli.StringFlag{
-						Name:    "next-update",
-						Aliases: []string{"nu", "N"},
-						Usage:   "Set the 'next update' time for the CRL (RFC3339 format). If not specified, uses the NotAfter time of the issuing certificate",
-						Validator: func(s string) error {
-							_, err := util.ParseTime(s)
-							if err != nil {
-								return cli.Exit(fmt.Sprintf("invalid time format for --next-update/-n: %v", err), 1)
-							}
-							return nil
-						},
+						Name:    "format",
+						Aliases: []string{"f"},
+						Usage:   "Output format: text or json",
+						Value:   "text",
 					},
-					&cli.BoolFlag{
-						Name:    "to-be-signed",
-						Aliases: []string{"tbs", "t"},
-						Usage:   "Output the 'to be signed' portion of the CRL in PEM format to stdout instead of creating a signed CRL.",
+				},
+			},
+			{
+				Name:  "check",
+				Usage: "Check whether certificates or serial numbers are revoked by CRLs of the issuers given with --crt.",
+				Action: func(ctx context.Context, c *cli.Command) error {
+					return cmdCheck(ctx, c)
+				},
+				Flags: []cli.Flag{
+					&cli.StringSliceFlag{
+						Name:    "crl",
+						Aliases: []string{"l"},
+						Usage:   "CRL to check against, such as a complete CRL and its delta CRL (may be repeated)",
 					},
-					&cli.StringFlag{
-						Name:    "digest",
+					&cli.StringSliceFlag{
+						Name:  "certs",
+						Usage: "Certificate file, PEM bundle or directory of certificates to check (may be repeated)",
+					},
+					&cli.StringSliceFlag{
+						Name:    "serial",
 						Aliases: []string{"s"},
-						Usage:   "Target file to output the digest signature of the TBS CRL when using --to-be-signed/--tbs.",
+						Usage:   "Serial number (in hexadecimal) of a certificate of the CRL issuer to check (may be repeated)",
+					},
+					&cli.StringFlag{
+						Name:    "format",
+						Aliases: []string{"f"},
+						Usage:   "Output format: text or json",
+						Value:   "text",
 					},
+					atFlag(),
 				},
 			},
 			{
-				Name:  "assemble",
-				Usage: "Assemble a CRL from a Cert, TBS CRL, and a signature from the issuing CA.",
+				Name:  "key",
+				Usage: "Manage the issuer private key.",
+				Commands: []*cli.Command{
+					{
+						Name:  "rewrap",
+						Usage: "Re-encrypt a legacy encrypted private key as PKCS#8 with scrypt or PBKDF2, replacing it once it is verified against --crt.",
+						Action: func(ctx context.Context, c *cli.Command) error {
+							return cmdKeyRewrap(ctx, c)
+						},
+						Flags: flags(
+							keyFileFlags(),
+							[]cli.Flag{
+								&cli.StringFlag{
+									Name:  "new-password-source",
+									Usage: "Read the new password from env:<var>, file:<path>, fd:<n>, cmd:<command>, pinentry:<program>, shamir or prompt",
+									Value: "prompt",
+								},
+								&cli.StringFlag{
+									Name:  "kdf",
+									Usage: "Key derivation function: scrypt or pbkdf2",
+									Value: "scrypt",
+								},
+								&cli.StringFlag{
+									Name:  "cipher",
+									Usage: "Cipher: aes-256-cbc or aes-256-gcm (aes-256-gcm keys cannot be read by OpenSSL)",
+									Value: "aes-256-cbc",
+								},
+								&cli.IntFlag{
+									Name:  "scrypt-n",
+									Usage: "scrypt CPU/memory cost N, a power of two. OpenSSL cannot read keys with an N above 16384 by default",
+									Value: 1 << 14,
+								},
+								&cli.IntFlag{
+									Name:  "scrypt-r",
+									Usage: "scrypt block size r",
+									Value: 8,
+								},
+								&cli.IntFlag{
+									Name:  "scrypt-p",
+									Usage: "scrypt parallelization p",
+									Value: 1,
+								},
+								&cli.IntFlag{
+									Name:  "pbkdf2-iterations",
+									Usage: "PBKDF2-HMAC-SHA256 iteration count",
+									Value: 600000,
+								},
+							},
+						),
+					},
+				},
+			},
+			{
+				Name:  "split",
+				Usage: "Split a key passphrase into Shamir shares for key custodians, printed one per line.",
 				Action: func(ctx context.Context, c *cli.Command) error {
-					return cmdAssemble(ctx, c)
+					return cmdSplit(ctx, c)
 				},
 				Flags: []cli.Flag{
-					&cli.StringFlag{
-						Name:    "to-be-signed",
-						Aliases: []string{"tbs", "t"},
-						Usage:   "The TBS CRL file to use for assembling the final CRL.",
+					&cli.IntFlag{
+						Name:     "shares",
+						Aliases:  []string{"n"},
+						Usage:    "Number of shares to create, one per custodian.",
+						Required: true,
+					},
+					&cli.IntFlag{
+						Name:     "threshold",
+						Aliases:  []string{"m"},
+						Usage:    "Number of shares needed to reconstruct the passphrase with --password-source shamir.",
+						Required: true,
 					},
 					&cli.StringFlag{
-						Name:    "signature",
-						Aliases: []string{"s"},
-						Usage:   "The signature file to use for assembling the final CRL.",
+						Name:  "password-source",
+						Usage: "Read the passphrase from env:<var>, file:<path>, fd:<n>, cmd:<command>, pinentry:<program> or prompt",
+						Value: "prompt",
 					},
 				},
 			},
@@ -146,7 +336,15 @@ func init() {
 			&cli.StringFlag{
 				Name:    "crt",
 				Aliases: []string{"c"},
-				Usage:   "Path to the issuing certificate file (used for generation and assembly from TBS).",
+				Usage:   "Path to the issuing certificate file, a certificate bundle or a directory (used for generation and assembly from TBS).",
+			},
+			&cli.StringFlag{
+				Name:  "crt-subject",
+				Usage: "Select the issuing certificate from a --crt bundle or directory by subject DN or common name.",
+			},
+			&cli.StringFlag{
+				Name:  "crt-ski",
+				Usage: "Select the issuing certificate from a --crt bundle or directory by subject key identifier (hex).",
 			},
 			&cli.BoolFlag{
 				Name:  "pem",
@@ -159,11 +357,6 @@ func init() {
 func main() {
 	if err := app.Run(context.Background(), os.Args); err != nil {
 		if errExit, ok := err.(cli.ExitCoder); ok {
-			if Version == "dev" {
-				if errExit.ExitCode() != 0 {
-					os.Exit(0)
-				}
-			}
 			os.Exit(errExit.ExitCode())
 		}
 		log.Fatal().Err(err).Msg("application error")
@@ -171,37 +364,49 @@ func main() {
 }
 
 func cmdAssemble(_ context.Context, c *cli.Command) error {
-	tbsPath := c.String("to-be-signed")
-	if tbsPath == "" {
-		return cli.Exit("TBS CRL path must be specified with --to-be-signed/-t", 1)
+	// An encrypted PKCS#12 --crt bundle needs its password to read the certificates
+	password, err := readPassword(c)
+	if err != nil {
+		return err
 	}
-	tbs, err := util.ParseTBSCRL(tbsPath)
+
+	tbs, crt, req, err := readTBSInputs(c, false, password)
 	if err != nil {
-		return cli.Exit(fmt.Sprintf("failed to parse TBS CRL: %v", err), 1)
+		return err
 	}
 
 	signaturePath := c.String("signature")
 	if signaturePath == "" {
 		return cli.Exit("signature path must be specified with --signature/-s", 1)
 	}
-	signature, err := util.ReadSignatureFile(signaturePath)
+	if req != nil {
+		if err := req.CheckSignatureFile(signaturePath); err != nil {
+			return cli.Exit(err.Error(), 1)
+		}
+	}
+	signatureFormat, err := util.ParseSignatureFormat(c.String("signature-format"))
 	if err != nil {
-		return cli.Exit(fmt.Sprintf("failed to read signature file: %v", err), 1)
+		return cli.Exit(fmt.Sprintf("invalid --signature-format: %v", err), 1)
 	}
-
-	issuerCrtPath := c.String("crt")
-	if issuerCrtPath == "" {
-		return cli.Exit("issuer certificate path must be specified with --crt/-c", 1)
+	// ECDSA signatures in r||s form are converted using the curve of the issuer certificate
+	signature, err := util.ReadSignatureFile(signaturePath, signatureFormat, crt.PublicKey)
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("failed to read signature file: %v", err), 1)
 	}
 
-	crt, err := util.ParseCertificate(issuerCrtPath)
-	if err != nil {
-		return cli.Exit(fmt.Sprintf("failed to parse issuer certificate: %v", err), 1)
+	// Default to the signature algorithm the TBS CRL was created with
+	var sigAlg x509.SignatureAlgorithm
+	if c.String("sig-alg") != "" {
+		sigAlg, err = readSignatureAlgorithm(c, crt)
+		if err != nil {
+			return err
+		}
 	}
 
 	err = crl.AssembleCRL(crt, *tbs, signature, &crl.AssembleCRLParams{
-		OutPath: c.String("out"),
-		OutPEM:  c.Bool("pem"),
+		OutPath:            c.String("out"),
+		OutPEM:             c.Bool("pem"),
+		SignatureAlgorithm: sigAlg,
 	})
 	if err != nil {
 		return cli.Exit(fmt.Sprintf("failed to assemble CRL: %v", err), 1)
@@ -211,23 +416,28 @@ func cmdAssemble(_ context.Context, c *cli.Command) error {
 }
 
 func cmdCreate(_ context.Context, c *cli.Command) error {
-	var serialsInclude, serialsIgnore []string
+	var serialsIgnore []string
 	var err error
 
 	// Check if TBS output is requested
 	tbs := c.Bool("to-be-signed")
 	digestPath := c.String("digest")
-	if tbs && digestPath == "" {
-		return cli.Exit("target digest path must be specified when creating a TBS CRL", 1)
+	requestPath := c.String("request")
+	if tbs && digestPath == "" && requestPath == "" {
+		return cli.Exit("target digest or signing request path must be specified when creating a TBS CRL", 1)
+	}
+	if !tbs && requestPath != "" {
+		return cli.Exit("--request can only be used with --to-be-signed/--tbs", 1)
 	}
 
-	// Read serial numbers of certificates to include in the CRL
-	serialsPath := c.String("serials")
-	if serialsPath != "" {
-		serialsInclude, err = util.ReadSerialNumbersFromFile(serialsPath)
-		if err != nil {
-			return cli.Exit(fmt.Sprintf("failed to read serials file: %v", err), 1)
-		}
+	digestFormat, err := readDigestFormat(c)
+	if err != nil {
+		return err
+	}
+
+	serialsInclude, revocations, err := readRevocations(c)
+	if err != nil {
+		return err
 	}
 
 	// Read serial numbers of certificates to ignore in the CRL (removes from extended CRLs)
@@ -239,79 +449,70 @@ func cmdCreate(_ context.Context, c *cli.Command) error {
 		}
 	}
 
-	// Parse issuer certificate and private key
-	issuerCrtPath := c.String("crt")
-	issuerKeyPath := c.String("key")
-
-	if tbs && issuerKeyPath != "" {
-		return cli.Exit("issuer private key should not be specified when creating a TBS CRL", 1)
+	arl := c.Bool("arl")
+	certsPath := c.String("certs")
+	if certsPath != "" && !arl {
+		return cli.Exit("--certs can only be used when creating an ARL with --arl", 1)
 	}
 
-	if issuerCrtPath == "" {
-		return cli.Exit("issuer certificate path must be specified with --crt/-c", 1)
-	}
-
-	if !tbs && issuerKeyPath == "" {
-		return cli.Exit("issuer private key path must be specified with --key/-k", 1)
+	crt, key, err := loadIssuer(c, tbs)
+	if err != nil {
+		return err
 	}
+	defer closeKey(key)
 
-	crt, err := util.ParseCertificate(issuerCrtPath)
+	sigAlg, err := readSignatureAlgorithm(c, crt)
 	if err != nil {
-		return cli.Exit(fmt.Sprintf("failed to parse issuer certificate: %v", err), 1)
+		return err
 	}
 
-	password := c.String("password")
-	passwordPrompt := c.Bool("password-prompt")
-	if tbs && (password != "" || passwordPrompt) {
-		return cli.Exit("password should not be specified when creating a TBS CRL", 1)
+	// Parse this-update and next-update times
+	updateThis, updateNext, err := readValidity(c)
+	if err != nil {
+		return err
 	}
 
-	if passwordPrompt {
-		password, err = util.PromptPassword("Enter the private key password")
-		if err != nil {
-			return cli.Exit(fmt.Sprintf("failed to read private key password: %v", err), 1)
-		}
+	// Extract existing revocation entries from CRLs, ignore serials in the ignore list
+	extendPaths := c.StringSlice("extend")
+	crlNumber, entries, err := crl.ExtractRevocationEntries(serialsIgnore, extendPaths...)
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("failed to extract revocation entries from existing CRLs: %v", err), 1)
 	}
 
-	if tbs {
-		password = "" // no password needed when not signing
+	// Carry forward the scope of the extended CRLs unless overridden on the command line
+	idp, err := readIssuingDistributionPoint(c, extendPaths...)
+	if err != nil {
+		return err
 	}
 
-	var key crypto.Signer = nil
-
-	if !tbs {
-		key, err = util.ParsePrivateSigner(issuerKeyPath, password)
-		if err != nil {
-			return cli.Exit(fmt.Sprintf("failed to parse issuer private key: %v", err), 1)
-		}
-
-		if key == nil {
-			return cli.Exit("issuer private key could not be parsed", 1)
+	if arl {
+		if idp == nil {
+			idp = &crl.IssuingDistributionPoint{}
 		}
-
-		// Verify that the provided certificate and private key actually match
-		if err := util.VerifyCrtKeyMatch(crt, key); err != nil {
-			return cli.Exit(fmt.Sprintf("issuer certificate and private key do not match: %v", err), 1)
+		if idp.OnlyContainsUserCerts {
+			return cli.Exit("an ARL cannot be restricted to user certificates", 1)
 		}
-	}
-
-	// Parse this-update and next-update times
+		idp.OnlyContainsCACerts = true
 
-	updateThisStr, err := util.ParseTime(c.String("this-update"))
-	if err != nil {
-		return cli.Exit(fmt.Sprintf("failed to parse this-update time: %v", err), 1)
-	}
+		// Refuse to revoke end-entity certificates in an ARL
+		if certsPath != "" {
+			issued, err := util.ReadCertificatesDir(certsPath)
+			if err != nil {
+				return cli.Exit(fmt.Sprintf("failed to read issued certificates: %v", err), 1)
+			}
 
-	updateNextStr, err := util.ParseTime(c.String("next-update"))
-	if err != nil {
-		return cli.Exit(fmt.Sprintf("failed to parse next-update time: %v", err), 1)
-	}
+			serials := append([]string{}, serialsInclude...)
+			for _, record := range revocations {
+				serials = append(serials, record.Serial)
+			}
+			for _, entry := range entries {
+				serials = append(serials, entry.SerialNumber.Text(16))
+			}
 
-	// Extract existing revocation entries from CRLs, ignore serials in the ignore list
-	extendPaths := c.StringSlice("extend")
-	crlNumber, entries, err := crl.ExtractRevocationEntries(serialsIgnore, extendPaths...)
-	if err != nil {
-		return cli.Exit(fmt.Sprintf("failed to extract revocation entries from existing CRLs: %v", err), 1)
+			if err := util.VerifyCASerials(crt, issued, serials); err != nil {
+				return cli.Exit(fmt.Sprintf("serial cannot be included in an ARL: %v", err), 1)
+			}
+		}
 	}
 
 	// Determine CRL number to use, either from flag or by incrementing existing highest number
@@ -329,16 +530,23 @@ func cmdCreate(_ context.Context, c *cli.Command) error {
 
 	// Create the CRL
 	err = crl.CreateCRL(crt, key, &crl.CreateCRLParams{
-		SerialsInclude: serialsInclude,
-		SerialsIgnore:  serialsIgnore,
-		Entries:        entries,
-		TBS:            tbs,
-		DigestPath:     digestPath,
-		OutPath:        c.String("out"),
-		OutPEM:         c.Bool("pem"),
-		CRLNumber:      crlNumber,
-		ThisUpdate:     updateThisStr,
-		NextUpdate:     updateNextStr,
+		SerialsInclude:     serialsInclude,
+		SerialsIgnore:      serialsIgnore,
+		Revocations:        revocations,
+		Entries:            entries,
+		TBS:                tbs,
+		DigestPath:         digestPath,
+		DigestFormat:       digestFormat,
+		RequestPath:        requestPath,
+		OutPath:            c.String("out"),
+		OutPEM:             c.Bool("pem"),
+		SignatureAlgorithm: sigAlg,
+		CRLNumber:          crlNumber,
+		FreshestCRL:        c.StringSlice("freshest-crl"),
+		ThisUpdate:         updateThis,
+		NextUpdate:         updateNext,
+
+		IssuingDistributionPoint: idp,
 	})
 	if err != nil {
 		return cli.Exit(fmt.Sprintf("failed to create CRL: %v", err), 1)
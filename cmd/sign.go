@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/goodieshq/revokr/pkg/crl"
+	"github.com/goodieshq/revokr/pkg/util"
+	"github.com/rs/zerolog/log"
+	"github.com/urfave/cli/v3"
+)
+
+func cmdSign(_ context.Context, c *cli.Command) error {
+	signaturePath := c.String("signature")
+	if signaturePath == "" {
+		return cli.Exit("signature output path must be specified with --signature/-s", 1)
+	}
+
+	password, err := readPassword(c)
+	if err != nil {
+		return err
+	}
+
+	tbs, crt, req, err := readTBSInputs(c, true, password)
+	if err != nil {
+		return err
+	}
+
+	rl, err := util.ParseTBS(tbs.FullBytes)
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("failed to parse TBS CRL: %v", err), 1)
+	}
+
+	if crt != nil && !bytes.Equal(rl.RawIssuer, crt.RawSubject) {
+		return cli.Exit(fmt.Sprintf("TBS CRL issuer %q does not match the subject of the issuer certificate %q", rl.Issuer, crt.Subject), 1)
+	}
+
+	// Show the signer what they are about to sign
+	describeTBS(os.Stderr, rl)
+	if req != nil {
+		fmt.Fprintf(os.Stderr, "Signing request:      %s\n", req.Digest)
+	}
+
+	if !c.Bool("yes") {
+		ok, err := confirm("Sign this CRL?")
+		if err != nil {
+			return cli.Exit(fmt.Sprintf("failed to read confirmation: %v", err), 1)
+		}
+		if !ok {
+			return cli.Exit("signing aborted", 1)
+		}
+	}
+
+	key, err := loadKey(c, password)
+	if err != nil {
+		return err
+	}
+	defer closeKey(key)
+
+	if crt != nil {
+		if err := util.VerifyCrtKeyMatch(crt, key); err != nil {
+			return cli.Exit(fmt.Sprintf("issuer certificate and private key do not match: %v", err), 1)
+		}
+	}
+
+	signature, err := crl.SignTBS(tbs.FullBytes, key)
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("failed to sign TBS CRL: %v", err), 1)
+	}
+
+	if crt != nil {
+		if err := crt.CheckSignature(rl.SignatureAlgorithm, tbs.FullBytes, signature); err != nil {
+			return cli.Exit(fmt.Sprintf("signature verification failed: %v", err), 1)
+		}
+	} else {
+		log.Warn().Msg("no issuer certificate given, the signature is not verified")
+	}
+
+	if err := crl.WriteSignature(signaturePath, signature, req); err != nil {
+		return cli.Exit(err.Error(), 1)
+	}
+
+	log.Info().Str("path", signaturePath).Msg("Wrote signature of TBS CRL")
+	return nil
+}
+
+// describeTBS writes a human readable summary of a TBS CRL and every entry in it.
+func describeTBS(w io.Writer, rl *x509.RevocationList) {
+	fmt.Fprintf(w, "Issuer:               %s\n", rl.Issuer)
+	fmt.Fprintf(w, "CRL number:           %s\n", rl.Number)
+	if base, err := crl.DeltaBaseNumber(rl); err == nil && base != nil {
+		fmt.Fprintf(w, "Delta of base CRL:    %s\n", base)
+	}
+	fmt.Fprintf(w, "This update:          %s\n", rl.ThisUpdate.UTC().Format(time.RFC3339))
+	fmt.Fprintf(w, "Next update:          %s\n", rl.NextUpdate.UTC().Format(time.RFC3339))
+	fmt.Fprintf(w, "Signature algorithm:  %s\n", rl.SignatureAlgorithm)
+	fmt.Fprintf(w, "Entries:              %d\n", len(rl.RevokedCertificateEntries))
+
+	for _, entry := range rl.RevokedCertificateEntries {
+		fmt.Fprintf(w, "    %s  revoked %s  %s\n",
+			strings.ToUpper(entry.SerialNumber.Text(16)),
+			entry.RevocationTime.UTC().Format(time.RFC3339),
+			util.ReasonString(entry.ReasonCode),
+		)
+	}
+}
+
+// confirm asks a yes/no question on stderr and reads the answer from stdin.
+func confirm(question string) (bool, error) {
+	fmt.Fprintf(os.Stderr, "%s [y/N]: ", question)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/goodieshq/revokr/pkg/shamir"
+	"github.com/goodieshq/revokr/pkg/util"
+	"github.com/rs/zerolog/log"
+	"github.com/urfave/cli/v3"
+)
+
+func cmdSplit(_ context.Context, c *cli.Command) error {
+	n := int(c.Int("shares"))
+	threshold := int(c.Int("threshold"))
+	if threshold < 2 || threshold > n || n > 255 {
+		return cli.Exit("--threshold/-m must be at least 2 and at most --shares/-n, which must be at most 255", 1)
+	}
+
+	source := c.String("password-source")
+	password, err := util.ReadPassword(source, "Enter the passphrase to split")
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("failed to read passphrase: %v", err), 1)
+	}
+	if password == "" {
+		return cli.Exit("passphrase is empty", 1)
+	}
+
+	// a typo in an interactively entered passphrase would only show when the shares are combined
+	if source == "prompt" {
+		again, err := util.PromptPassword("Enter the passphrase again")
+		if err != nil {
+			return cli.Exit(fmt.Sprintf("failed to read passphrase: %v", err), 1)
+		}
+		if again != password {
+			return cli.Exit("passphrases do not match", 1)
+		}
+	}
+
+	shares, err := shamir.Split([]byte(password), n, threshold)
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("failed to split passphrase: %v", err), 1)
+	}
+
+	// make sure the shares reconstruct the passphrase before anyone relies on them
+	secret, err := shamir.Combine(shares[n-threshold:])
+	if err != nil || string(secret) != password {
+		return cli.Exit("internal error: shares do not reconstruct the passphrase", 1)
+	}
+	clear(secret)
+
+	for _, share := range shares {
+		fmt.Println(share)
+	}
+
+	log.Info().Msgf("Split passphrase into %d shares, any %d of which reconstruct it", n, threshold)
+	return nil
+}
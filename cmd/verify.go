@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/goodieshq/revokr/pkg/crl"
+	"github.com/goodieshq/revokr/pkg/util"
+	"github.com/rs/zerolog/log"
+	"github.com/urfave/cli/v3"
+)
+
+// exit codes of the verify command, 1 is left for usage and read errors
+const (
+	exitCRLInvalid     = 2
+	exitCRLExpired     = 3
+	exitCRLNotYetValid = 4
+)
+
+func cmdVerify(_ context.Context, c *cli.Command) error {
+	if c.Args().Len() != 1 {
+		return cli.Exit("verify takes exactly one CRL file", 1)
+	}
+	path := c.Args().First()
+
+	if c.String("crt") == "" {
+		return cli.Exit("issuer certificate or chain must be specified with --crt/-c", 1)
+	}
+	crts, err := util.ReadCertificates(c.String("crt"), "")
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("failed to parse issuer certificate: %v", err), 1)
+	}
+
+	at, err := readAt(c)
+	if err != nil {
+		return err
+	}
+
+	block, err := util.TryParsePEM(path)
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("failed to read CRL: %v", err), 1)
+	}
+
+	rl, issuer, err := crl.VerifyCRL(block.Bytes, crts, at)
+	switch {
+	case errors.Is(err, crl.ErrCRLExpired):
+		return cli.Exit(err.Error(), exitCRLExpired)
+	case errors.Is(err, crl.ErrCRLNotYetValid):
+		return cli.Exit(err.Error(), exitCRLNotYetValid)
+	case err != nil:
+		return cli.Exit(err.Error(), exitCRLInvalid)
+	}
+
+	log.Info().Str("subject", issuer.Subject.String()).Str("fingerprint", util.Fingerprint(issuer)).Msg("Using issuer certificate")
+	if !issuer.NotAfter.After(at) || issuer.NotBefore.After(at) {
+		log.Warn().Str("subject", issuer.Subject.String()).Msg("issuer certificate is not valid at the verification time")
+	}
+
+	event := log.Info().Str("path", path).Str("issuer", rl.Issuer.String()).Time("this_update", rl.ThisUpdate)
+	if rl.Number != nil {
+		event = event.Str("number", rl.Number.String())
+	}
+	if !rl.NextUpdate.IsZero() {
+		event = event.Time("next_update", rl.NextUpdate)
+	}
+	event.Msg("CRL is valid")
+	return nil
+}
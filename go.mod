@@ -4,14 +4,16 @@ go 1.25.5
 
 require (
 	github.com/jschauma/getpass v0.2.3
+	github.com/miekg/pkcs11 v1.1.2
 	github.com/rs/zerolog v1.34.0
 	github.com/urfave/cli/v3 v3.6.1
 	github.com/youmark/pkcs8 v0.0.0-20240726163527-a2c0da244d78
+	golang.org/x/crypto v0.22.0
+	software.sslmate.com/src/go-pkcs12 v0.4.0
 )
 
 require (
 	github.com/mattn/go-colorable v0.1.13 // indirect
 	github.com/mattn/go-isatty v0.0.19 // indirect
-	golang.org/x/crypto v0.22.0 // indirect
 	golang.org/x/sys v0.19.0 // indirect
 )
@@ -1,6 +1,7 @@
 package crl
 
 import (
+	"bytes"
 	"crypto/x509"
 	"encoding/asn1"
 	"fmt"
@@ -9,12 +10,27 @@ import (
 )
 
 type AssembleCRLParams struct {
-	OutPath string
-	OutPEM  bool
+	OutPath            string
+	OutPEM             bool
+	SignatureAlgorithm x509.SignatureAlgorithm // defaults to the algorithm of the TBS CRL
 }
 
 func AssembleCRL(crt *x509.Certificate, tbs asn1.RawValue, signature []byte, params *AssembleCRLParams) error {
-	sigAlgo, _, err := util.GetSignatureAlgAndHash(crt)
+	tbsCRL, err := util.ParseTBS(tbs.FullBytes)
+	if err != nil {
+		return fmt.Errorf("failed to parse TBS CRL: %w", err)
+	}
+
+	alg := params.SignatureAlgorithm
+	if alg == x509.UnknownSignatureAlgorithm {
+		alg = tbsCRL.SignatureAlgorithm
+	}
+
+	if err := util.CheckSignatureAlgorithm(alg, crt.PublicKey); err != nil {
+		return fmt.Errorf("invalid signature algorithm: %w", err)
+	}
+
+	sigAlgo, _, err := util.GetSignatureAlgAndHash(alg)
 	if err != nil {
 		return fmt.Errorf("failed to get signature algorithm: %w", err)
 	}
@@ -37,5 +53,53 @@ func AssembleCRL(crt *x509.Certificate, tbs asn1.RawValue, signature []byte, par
 		return fmt.Errorf("failed to marshal assembled CRL: %w", err)
 	}
 
+	if err := verifyAssembledCRL(crt, tbs.FullBytes, crl); err != nil {
+		return err
+	}
+
+	if err := lintBeforeWrite(crl); err != nil {
+		return err
+	}
+
 	return util.WriteCRL(params.OutPath, crl, params.OutPEM)
 }
+
+// verifyAssembledCRL checks that an assembled CRL is consistent with the TBS it was built from and is
+// correctly signed by the issuer certificate, so that a broken CRL is never written.
+func verifyAssembledCRL(crt *x509.Certificate, tbs, crl []byte) error {
+	var rcrl util.RawCRL
+	if _, err := asn1.Unmarshal(crl, &rcrl); err != nil {
+		return fmt.Errorf("failed to unmarshal assembled CRL: %w", err)
+	}
+
+	innerAlgo, err := util.TBSSignatureAlgorithm(tbs)
+	if err != nil {
+		return fmt.Errorf("failed to read TBS CRL signature algorithm: %w", err)
+	}
+	outerAlgo, err := asn1.Marshal(rcrl.SignatureAlgorithm)
+	if err != nil {
+		return fmt.Errorf("failed to marshal signature algorithm: %w", err)
+	}
+	if !bytes.Equal(innerAlgo, outerAlgo) {
+		return fmt.Errorf("signature algorithm of the TBS CRL does not match the signature algorithm of the assembled CRL")
+	}
+
+	rl, err := x509.ParseRevocationList(crl)
+	if err != nil {
+		return fmt.Errorf("failed to parse assembled CRL: %w", err)
+	}
+
+	if !bytes.Equal(rl.RawIssuer, crt.RawSubject) {
+		return fmt.Errorf("TBS CRL issuer %q does not match the subject of the issuer certificate %q", rl.Issuer, crt.Subject)
+	}
+
+	if len(crt.SubjectKeyId) > 0 && !bytes.Equal(rl.AuthorityKeyId, crt.SubjectKeyId) {
+		return fmt.Errorf("TBS CRL authority key identifier %X does not match the subject key identifier %X of the issuer certificate", rl.AuthorityKeyId, crt.SubjectKeyId)
+	}
+
+	if err := rl.CheckSignatureFrom(crt); err != nil {
+		return fmt.Errorf("signature verification of the assembled CRL failed: %w", err)
+	}
+
+	return nil
+}
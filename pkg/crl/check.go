@@ -0,0 +1,379 @@
+package crl
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/asn1"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/goodieshq/revokr/pkg/util"
+	"github.com/rs/zerolog/log"
+)
+
+// RevocationStatus is the status of a certificate according to a set of CRLs.
+type RevocationStatus string
+
+const (
+	StatusGood    RevocationStatus = "good"
+	StatusRevoked RevocationStatus = "revoked"
+	StatusUnknown RevocationStatus = "unknown" // no CRL covers the certificate, see Detail
+)
+
+// CheckedCertificate is the revocation status of a certificate or serial number. Subject and Issuer are
+// null for bare serial numbers. Detail explains an unknown status and qualifies the status of bare serial
+// numbers checked against CRLs of a limited scope. Stale is set when a CRL used was expired or not yet
+// valid at the time of the check, and Detail then names it.
+type CheckedCertificate struct {
+	Serial         string           `json:"serial"`
+	Subject        *string          `json:"subject"`
+	Issuer         *string          `json:"issuer"`
+	Status         RevocationStatus `json:"status"`
+	Reason         *string          `json:"reason"`
+	ReasonCode     *int             `json:"reason_code"`
+	RevocationTime *time.Time       `json:"revocation_time"`
+	InvalidityDate *time.Time       `json:"invalidity_date"`
+	CRL            *string          `json:"crl"`
+	Stale          bool             `json:"stale"`
+	Detail         *string          `json:"detail"`
+}
+
+// addDetail appends a sentence to the detail of the result.
+func (result *CheckedCertificate) addDetail(format string, args ...any) {
+	detail := fmt.Sprintf(format, args...)
+	if result.Detail != nil {
+		detail = *result.Detail + "; " + detail
+	}
+	result.Detail = &detail
+}
+
+// RevocationChecker looks up certificates in CRLs that were verified against their issuer. Complete CRLs
+// must be added before the delta CRLs issued against them.
+type RevocationChecker struct {
+	at     time.Time
+	scopes []*checkScope
+}
+
+// checkScope holds the revocation entries of one CRL issuer and issuing distribution point, from its
+// newest complete CRL and the delta CRLs applied on top of it.
+type checkScope struct {
+	issuer      *x509.Certificate
+	idp         *IssuingDistributionPoint // nil for a CRL covering all certificates and reasons
+	path        string
+	number      *big.Int // nil for a complete CRL without a CRL number
+	deltaNumber *big.Int
+	entries     map[string]checkEntry // keyed by entryKey
+	indirect    map[string]struct{}   // certificate issuers named by an indirect CRL
+	stale       []string              // why CRLs of the scope are not valid at the time of the check
+}
+
+type checkEntry struct {
+	entry x509.RevocationListEntry
+	path  string
+}
+
+// NewRevocationChecker returns a checker for the status of certificates at the given time. CRLs that are
+// not valid at that time are still used, but the results that depend on them are marked as stale.
+func NewRevocationChecker(at time.Time) *RevocationChecker {
+	return &RevocationChecker{at: at}
+}
+
+func (rc *RevocationChecker) scope(issuer *x509.Certificate, idp *IssuingDistributionPoint) *checkScope {
+	for _, scope := range rc.scopes {
+		if bytes.Equal(scope.issuer.RawSubject, issuer.RawSubject) &&
+			bytes.Equal(scope.issuer.RawSubjectPublicKeyInfo, issuer.RawSubjectPublicKeyInfo) &&
+			sameScope(scope.idp, idp) {
+			return scope
+		}
+	}
+	return nil
+}
+
+// sameScope reports whether two CRLs with the given issuing distribution points cover the same
+// certificates, which is the case for a complete CRL and the delta CRLs issued against it.
+func sameScope(a, b *IssuingDistributionPoint) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(b)
+}
+
+// AddCRL adds the CRL at path, which was parsed as rl and verified against issuer. Of several complete
+// CRLs of the same issuer and issuing distribution point only the newest is used. A complete CRL without
+// a CRL number is replaced by any complete CRL added after it. A delta CRL is applied to the numbered
+// complete CRL of its issuer and issuing distribution point, which must have been added before and be at
+// least as new as the base of the delta CRL.
+func (rc *RevocationChecker) AddCRL(path string, rl *x509.RevocationList, issuer *x509.Certificate) error {
+	baseNumber, err := DeltaBaseNumber(rl)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	idp, err := findIssuingDistributionPoint(rl.Extensions)
+	if err != nil {
+		return fmt.Errorf("invalid issuing distribution point in %s: %w", path, err)
+	}
+	if baseNumber != nil {
+		return rc.addDelta(path, rl, issuer, idp, baseNumber)
+	}
+
+	_, entries, err := ExtractRevocationEntries(nil, path)
+	if err != nil {
+		return fmt.Errorf("failed to extract revocation entries from %s: %w", path, err)
+	}
+
+	scope := rc.scope(issuer, idp)
+	if scope != nil && scope.number != nil && (rl.Number == nil || scope.number.Cmp(rl.Number) >= 0) {
+		log.Warn().Str("path", path).Msgf("a newer CRL of %s was already given, skipping", issuer.Subject)
+		return nil
+	}
+	if scope != nil && scope.deltaNumber != nil {
+		return fmt.Errorf("complete CRL %s must be given before the delta CRLs of its issuer", path)
+	}
+	if scope == nil {
+		scope = &checkScope{issuer: issuer, idp: idp}
+		rc.scopes = append(rc.scopes, scope)
+	}
+
+	scope.path = path
+	scope.number = rl.Number
+	scope.entries = make(map[string]checkEntry)
+	scope.indirect = make(map[string]struct{})
+	scope.stale = nil
+	if err := checkValidity(rl, rc.at); err != nil {
+		scope.stale = append(scope.stale, fmt.Sprintf("%s: %v", path, err))
+	}
+	for _, entry := range entries {
+		entryIssuer := entryIssuer(entry, rl.RawIssuer)
+		if !bytes.Equal(entryIssuer, rl.RawIssuer) {
+			scope.indirect[string(entryIssuer)] = struct{}{}
+		}
+		scope.entries[entryKey(entryIssuer, entry.SerialNumber.Text(16))] = checkEntry{entry: entry, path: path}
+	}
+
+	return nil
+}
+
+func (rc *RevocationChecker) addDelta(path string, rl *x509.RevocationList, issuer *x509.Certificate, idp *IssuingDistributionPoint, baseNumber *big.Int) error {
+	scope := rc.scope(issuer, idp)
+	if scope == nil {
+		return fmt.Errorf("delta CRL %s needs the complete CRL of %s with the same issuing distribution point, which must be given before it",
+			path, issuer.Subject)
+	}
+	if scope.number == nil {
+		return fmt.Errorf("delta CRL %s cannot be applied to %s, which has no CRL number", path, scope.path)
+	}
+	if rl.Number == nil {
+		return fmt.Errorf("delta CRL %s has no CRL number", path)
+	}
+	if scope.number.Cmp(baseNumber) < 0 {
+		return fmt.Errorf("delta CRL %s is issued against base CRL number %s, but the complete CRL has number %s",
+			path, baseNumber, scope.number)
+	}
+	if rl.Number.Cmp(scope.number) <= 0 || (scope.deltaNumber != nil && rl.Number.Cmp(scope.deltaNumber) <= 0) {
+		log.Warn().Str("path", path).Msgf("delta CRL number %s is not newer than the CRLs already given, skipping", rl.Number)
+		return nil
+	}
+	scope.deltaNumber = rl.Number
+	if err := checkValidity(rl, rc.at); err != nil {
+		scope.stale = append(scope.stale, fmt.Sprintf("%s: %v", path, err))
+	}
+
+	// entries of delta CRLs replace those of the complete CRL, removeFromCRL releases a certificate on hold
+	var err error
+	entryIssuer := rl.RawIssuer
+	for _, entry := range rl.RevokedCertificateEntries {
+		for _, ext := range entry.Extensions {
+			if ext.Id.Equal(oidExtensionCertificateIssuer) {
+				if entryIssuer, err = parseCertificateIssuer(ext.Value); err != nil {
+					return fmt.Errorf("invalid certificate issuer entry extension in %q: %w", path, err)
+				}
+			}
+		}
+		if !bytes.Equal(entryIssuer, rl.RawIssuer) {
+			scope.indirect[string(entryIssuer)] = struct{}{}
+		}
+
+		key := entryKey(entryIssuer, entry.SerialNumber.Text(16))
+		if entry.ReasonCode == util.ReasonRemoveFromCRL {
+			delete(scope.entries, key)
+			continue
+		}
+		scope.entries[key] = checkEntry{entry: entry, path: path}
+	}
+
+	return nil
+}
+
+// CheckCertificate reports the status of crt. The certificate must be signed by the issuer of a CRL, or be
+// named as a certificate issuer by an indirect CRL. It is only looked up in the CRLs whose issuing
+// distribution point covers it, i.e. not in a CRL of CA certificates only for an end-entity certificate.
+// If no CRL covers the certificate, or the CRLs only cover some revocation reasons and it is not revoked
+// for one of them, its status is unknown.
+func (rc *RevocationChecker) CheckCertificate(crt *x509.Certificate) CheckedCertificate {
+	subject, issuer := crt.Subject.String(), crt.Issuer.String()
+	result := CheckedCertificate{
+		Serial:  crt.SerialNumber.Text(16),
+		Subject: &subject,
+		Issuer:  &issuer,
+	}
+
+	var scopes []*checkScope
+	var mismatch *checkScope
+	for _, scope := range rc.scopes {
+		if !bytes.Equal(scope.issuer.RawSubject, crt.RawIssuer) {
+			continue
+		}
+		if err := crt.CheckSignatureFrom(scope.issuer); err != nil {
+			mismatch = scope
+			continue
+		}
+		scopes = append(scopes, scope)
+	}
+	if len(scopes) == 0 {
+		for _, scope := range rc.scopes {
+			if _, ok := scope.indirect[string(crt.RawIssuer)]; ok {
+				scopes = append(scopes, scope)
+			}
+		}
+	}
+	if len(scopes) == 0 {
+		result.Status = StatusUnknown
+		if mismatch != nil {
+			result.addDetail("certificate is not signed by the CRL issuer %s (fingerprint %s)", mismatch.issuer.Subject, util.Fingerprint(mismatch.issuer))
+		} else {
+			result.addDetail("no CRL of the certificate issuer %s was given", issuer)
+		}
+		return result
+	}
+
+	// RFC 5280 section 5.2.5, CRLs of CA certificates only say nothing about end-entity certificates and
+	// the other way round
+	isCA := crt.BasicConstraintsValid && crt.IsCA
+	var covering []*checkScope
+	var outside []string
+	for _, scope := range scopes {
+		switch {
+		case scope.idp != nil && scope.idp.OnlyContainsCACerts && !isCA:
+			outside = append(outside, fmt.Sprintf("CRL %s only covers CA certificates", scope.path))
+		case scope.idp != nil && scope.idp.OnlyContainsUserCerts && isCA:
+			outside = append(outside, fmt.Sprintf("CRL %s only covers end-entity certificates", scope.path))
+		default:
+			covering = append(covering, scope)
+		}
+	}
+	if len(covering) == 0 {
+		result.Status = StatusUnknown
+		result.addDetail("%s", strings.Join(outside, "; "))
+		return result
+	}
+
+	return lookup(result, covering, crt.RawIssuer)
+}
+
+// CheckSerial reports the status of a certificate given by its serial number. The certificate issuer is
+// not known, so all CRLs must have the same issuer. Whether the certificate is a CA certificate is not
+// known either, so a good status from CRLs that only cover CA or end-entity certificates says so in the
+// detail.
+func (rc *RevocationChecker) CheckSerial(serial string) CheckedCertificate {
+	result := CheckedCertificate{Serial: serial}
+
+	var issuers []*x509.Certificate
+	for _, scope := range rc.scopes {
+		if !slices.ContainsFunc(issuers, func(issuer *x509.Certificate) bool {
+			return bytes.Equal(issuer.RawSubject, scope.issuer.RawSubject) &&
+				bytes.Equal(issuer.RawSubjectPublicKeyInfo, scope.issuer.RawSubjectPublicKeyInfo)
+		}) {
+			issuers = append(issuers, scope.issuer)
+		}
+	}
+	if len(issuers) != 1 {
+		result.Status = StatusUnknown
+		result.addDetail("serial numbers can only be checked against the CRLs of a single issuer, got %d issuers", len(issuers))
+		return result
+	}
+
+	result = lookup(result, rc.scopes, issuers[0].RawSubject)
+	if result.Status == StatusGood {
+		coversCA := slices.ContainsFunc(rc.scopes, func(scope *checkScope) bool {
+			return scope.idp == nil || !scope.idp.OnlyContainsUserCerts
+		})
+		coversUser := slices.ContainsFunc(rc.scopes, func(scope *checkScope) bool {
+			return scope.idp == nil || !scope.idp.OnlyContainsCACerts
+		})
+		if !coversUser {
+			result.addDetail("the CRLs only cover CA certificates, the status does not apply to end-entity certificates")
+		} else if !coversCA {
+			result.addDetail("the CRLs only cover end-entity certificates, the status does not apply to CA certificates")
+		}
+	}
+	return result
+}
+
+// lookup looks up the certificate of the issuer in the scopes that cover it. It is revoked if any of them
+// revokes it, and good if none does and together they cover all revocation reasons.
+func lookup(result CheckedCertificate, scopes []*checkScope, issuer []byte) CheckedCertificate {
+	for _, scope := range scopes {
+		for _, stale := range scope.stale {
+			result.Stale = true
+			result.addDetail("%s", stale)
+		}
+	}
+
+	for _, scope := range scopes {
+		if found, ok := scope.entries[entryKey(issuer, result.Serial)]; ok {
+			return found.revoked(result)
+		}
+	}
+
+	// RFC 5280 section 6.3.3, a certificate is only known to be good once all reasons are covered
+	covered := make(map[int]bool)
+	for _, scope := range scopes {
+		if scope.idp == nil || len(scope.idp.OnlySomeReasons) == 0 {
+			result.Status = StatusGood
+			return result
+		}
+		for _, reason := range scope.idp.OnlySomeReasons {
+			covered[reason] = true
+		}
+	}
+	var missing []string
+	for reason := range reasonFlagBits {
+		if !covered[reason] {
+			missing = append(missing, util.ReasonString(reason))
+		}
+	}
+	if len(missing) > 0 {
+		slices.Sort(missing)
+		result.Status = StatusUnknown
+		result.addDetail("the CRLs do not cover the revocation reasons %s", strings.Join(missing, ", "))
+		return result
+	}
+
+	result.Status = StatusGood
+	return result
+}
+
+// revoked fills in the revocation details of the entry.
+func (found checkEntry) revoked(result CheckedCertificate) CheckedCertificate {
+	entry := found.entry
+	revoked := entry.RevocationTime.UTC()
+	result.Status, result.RevocationTime, result.CRL = StatusRevoked, &revoked, &found.path
+	for _, ext := range entry.Extensions {
+		switch {
+		case ext.Id.Equal(oidExtensionReasonCode):
+			code := entry.ReasonCode
+			reason := util.ReasonString(code)
+			result.ReasonCode, result.Reason = &code, &reason
+		case ext.Id.Equal(oidExtensionInvalidityDate):
+			var t time.Time
+			if _, err := asn1.Unmarshal(ext.Value, &t); err == nil {
+				t = t.UTC()
+				result.InvalidityDate = &t
+			}
+		}
+	}
+	return result
+}
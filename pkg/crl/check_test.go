@@ -0,0 +1,311 @@
+package crl
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/goodieshq/revokr/pkg/util"
+)
+
+// testdata/unnumbered.crl is a v2 CRL without a CRL number revoking serial 1234 with keyCompromise,
+// written by "openssl ca -gencrl" without a crlnumber file for the CA in testdata/unnumbered-ca.*.
+
+func loadUnnumberedCRL(t *testing.T) (*x509.RevocationList, *x509.Certificate) {
+	t.Helper()
+	issuer, err := util.ParseCertificate("testdata/unnumbered-ca.crt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := util.TryParsePEM("testdata/unnumbered.crl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rl, _, err := VerifyCRL(block.Bytes, []*x509.Certificate{issuer}, time.Now())
+	if err != nil {
+		t.Fatalf("failed to verify CRL: %v", err)
+	}
+	if rl.Number != nil {
+		t.Fatal("fixture unexpectedly has a CRL number")
+	}
+	return rl, issuer
+}
+
+// writeTestCRL signs a CRL with the fixture CA key and writes it to a temporary file.
+func writeTestCRL(t *testing.T, issuer *x509.Certificate, template *x509.RevocationList) (string, *x509.RevocationList) {
+	t.Helper()
+	key, err := util.ParsePrivateSigner("testdata/unnumbered-ca.key", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.CreateRevocationList(rand.Reader, template, issuer, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rl, err := x509.ParseRevocationList(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "test.crl")
+	if err := os.WriteFile(path, der, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path, rl
+}
+
+func TestExtractRevocationEntriesUnnumbered(t *testing.T) {
+	number, entries, err := ExtractRevocationEntries(nil, "testdata/unnumbered.crl")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if number.Cmp(big.NewInt(-1)) != 0 {
+		t.Errorf("CRL number = %s, want -1 for a CRL without a number", number)
+	}
+	if len(entries) != 1 || entries[0].SerialNumber.Text(16) != "1234" {
+		t.Errorf("got entries %v, want serial 1234", entries)
+	}
+}
+
+func TestRevocationCheckerUnnumbered(t *testing.T) {
+	rl, issuer := loadUnnumberedCRL(t)
+
+	checker := NewRevocationChecker(time.Now())
+	if err := checker.AddCRL("testdata/unnumbered.crl", rl, issuer); err != nil {
+		t.Fatalf("AddCRL failed: %v", err)
+	}
+	if result := checker.CheckSerial("1234"); result.Status != StatusRevoked || *result.Reason != "keyCompromise" {
+		t.Errorf("serial 1234: got %s, want revoked with keyCompromise", result.Status)
+	}
+	if result := checker.CheckSerial("5678"); result.Status != StatusGood {
+		t.Errorf("serial 5678: got %s, want good", result.Status)
+	}
+
+	// A delta CRL cannot be applied to a complete CRL without a number
+	deltaIndicator, err := deltaCRLIndicatorExtension(big.NewInt(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	deltaPath, delta := writeTestCRL(t, issuer, &x509.RevocationList{
+		Number:          big.NewInt(2),
+		ThisUpdate:      time.Now(),
+		NextUpdate:      time.Now().Add(time.Hour),
+		ExtraExtensions: []pkix.Extension{deltaIndicator},
+	})
+	err = checker.AddCRL(deltaPath, delta, issuer)
+	if err == nil || !strings.Contains(err.Error(), "has no CRL number") {
+		t.Fatalf("delta CRL on an unnumbered CRL: got %v, want an error", err)
+	}
+
+	// A numbered complete CRL given after the unnumbered one replaces it
+	numberedPath, numbered := writeTestCRL(t, issuer, &x509.RevocationList{
+		Number:     big.NewInt(1),
+		ThisUpdate: time.Now(),
+		NextUpdate: time.Now().Add(time.Hour),
+		RevokedCertificateEntries: []x509.RevocationListEntry{
+			{SerialNumber: big.NewInt(0x5678), RevocationTime: time.Now()},
+		},
+	})
+	if err := checker.AddCRL(numberedPath, numbered, issuer); err != nil {
+		t.Fatalf("AddCRL of the numbered CRL failed: %v", err)
+	}
+	if result := checker.CheckSerial("5678"); result.Status != StatusRevoked {
+		t.Errorf("serial 5678: got %s, want revoked", result.Status)
+	}
+	if err := checker.AddCRL(deltaPath, delta, issuer); err != nil {
+		t.Fatalf("delta CRL on the numbered CRL failed: %v", err)
+	}
+
+	// An unnumbered CRL given after a numbered one is not newer
+	if err := checker.AddCRL("testdata/unnumbered.crl", rl, issuer); err != nil {
+		t.Fatalf("AddCRL failed: %v", err)
+	}
+	if result := checker.CheckSerial("5678"); result.Status != StatusRevoked {
+		t.Errorf("serial 5678: got %s after adding an unnumbered CRL, want revoked", result.Status)
+	}
+}
+
+// issueTestCertificate issues a certificate from the fixture CA.
+func issueTestCertificate(t *testing.T, issuer *x509.Certificate, serial int64, isCA bool) *x509.Certificate {
+	t.Helper()
+	key, err := util.ParsePrivateSigner("testdata/unnumbered-ca.key", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: fmt.Sprintf("Test %d", serial)},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, issuer, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crt, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return crt
+}
+
+// writeScopedCRL writes a complete CRL with the issuing distribution point revoking the serials.
+func writeScopedCRL(t *testing.T, issuer *x509.Certificate, idp *IssuingDistributionPoint, nextUpdate time.Time, serials ...int64) (string, *x509.RevocationList) {
+	t.Helper()
+	template := &x509.RevocationList{
+		Number:     big.NewInt(1),
+		ThisUpdate: time.Now().Add(-2 * time.Hour),
+		NextUpdate: nextUpdate,
+	}
+	if idp != nil {
+		ext, err := idp.extension()
+		if err != nil {
+			t.Fatal(err)
+		}
+		template.ExtraExtensions = []pkix.Extension{ext}
+	}
+	for _, serial := range serials {
+		template.RevokedCertificateEntries = append(template.RevokedCertificateEntries, x509.RevocationListEntry{
+			SerialNumber: big.NewInt(serial), RevocationTime: time.Now().Add(-time.Hour), ReasonCode: util.ReasonKeyCompromise,
+		})
+	}
+	return writeTestCRL(t, issuer, template)
+}
+
+func TestRevocationCheckerScope(t *testing.T) {
+	_, issuer := loadUnnumberedCRL(t)
+	ee := issueTestCertificate(t, issuer, 0x10, false)
+	revokedEE := issueTestCertificate(t, issuer, 0x11, false)
+	subCA := issueTestCertificate(t, issuer, 0x20, true)
+
+	valid := time.Now().Add(time.Hour)
+	arl := &IssuingDistributionPoint{OnlyContainsCACerts: true}
+	userCRL := &IssuingDistributionPoint{OnlyContainsUserCerts: true}
+	compromise := &IssuingDistributionPoint{OnlySomeReasons: []int{util.ReasonKeyCompromise, util.ReasonCACompromise}}
+	other := &IssuingDistributionPoint{OnlySomeReasons: []int{
+		util.ReasonAffiliationChanged, util.ReasonSuperseded, util.ReasonCessationOfOperation,
+		util.ReasonCertificateHold, util.ReasonPrivilegeWithdrawn, util.ReasonAACompromise,
+	}}
+
+	type crlSpec struct {
+		idp        *IssuingDistributionPoint
+		nextUpdate time.Time
+		serials    []int64
+	}
+	type want struct {
+		status RevocationStatus
+		stale  bool
+		detail string // contained in the detail, empty for none
+	}
+	tests := []struct {
+		name   string
+		crls   []crlSpec
+		crt    *x509.Certificate
+		serial string // checked instead of crt
+		want   want
+	}{
+		{
+			name: "end-entity certificate on an ARL",
+			crls: []crlSpec{{arl, valid, nil}},
+			crt:  ee,
+			want: want{StatusUnknown, false, "only covers CA certificates"},
+		},
+		{
+			name: "CA certificate on an ARL",
+			crls: []crlSpec{{arl, valid, nil}},
+			crt:  subCA,
+			want: want{StatusGood, false, ""},
+		},
+		{
+			name: "CA certificate on a CRL of end-entity certificates",
+			crls: []crlSpec{{userCRL, valid, []int64{0x20}}},
+			crt:  subCA,
+			want: want{StatusUnknown, false, "only covers end-entity certificates"},
+		},
+		{
+			name: "end-entity certificate on an ARL and a CRL of end-entity certificates",
+			crls: []crlSpec{{arl, valid, []int64{0x11}}, {userCRL, valid, []int64{0x11}}},
+			crt:  revokedEE,
+			want: want{StatusRevoked, false, ""},
+		},
+		{
+			name:   "serial on an ARL",
+			crls:   []crlSpec{{arl, valid, nil}},
+			serial: "10",
+			want:   want{StatusGood, false, "only cover CA certificates"},
+		},
+		{
+			name:   "serial on an ARL and a CRL of end-entity certificates",
+			crls:   []crlSpec{{arl, valid, nil}, {userCRL, valid, nil}},
+			serial: "10",
+			want:   want{StatusGood, false, ""},
+		},
+		{
+			name: "revoked in a partition of reasons",
+			crls: []crlSpec{{compromise, valid, []int64{0x11}}},
+			crt:  revokedEE,
+			want: want{StatusRevoked, false, ""},
+		},
+		{
+			name: "not revoked in a partition of reasons",
+			crls: []crlSpec{{compromise, valid, []int64{0x11}}},
+			crt:  ee,
+			want: want{StatusUnknown, false, "do not cover the revocation reasons aACompromise, affiliationChanged"},
+		},
+		{
+			name: "not revoked in partitions of all reasons",
+			crls: []crlSpec{{compromise, valid, []int64{0x11}}, {other, valid, nil}},
+			crt:  ee,
+			want: want{StatusGood, false, ""},
+		},
+		{
+			name: "expired CRL",
+			crls: []crlSpec{{nil, time.Now().Add(-time.Hour), nil}},
+			crt:  ee,
+			want: want{StatusGood, true, "CRL has expired"},
+		},
+		{
+			name: "expired CRL revoking the certificate",
+			crls: []crlSpec{{nil, time.Now().Add(-time.Hour), []int64{0x11}}},
+			crt:  revokedEE,
+			want: want{StatusRevoked, true, "CRL has expired"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := NewRevocationChecker(time.Now())
+			for _, spec := range tt.crls {
+				path, rl := writeScopedCRL(t, issuer, spec.idp, spec.nextUpdate, spec.serials...)
+				if err := checker.AddCRL(path, rl, issuer); err != nil {
+					t.Fatalf("AddCRL failed: %v", err)
+				}
+			}
+
+			var result CheckedCertificate
+			if tt.crt != nil {
+				result = checker.CheckCertificate(tt.crt)
+			} else {
+				result = checker.CheckSerial(tt.serial)
+			}
+
+			if result.Status != tt.want.status || result.Stale != tt.want.stale {
+				t.Errorf("got status %s and stale %v, want %s and %v", result.Status, result.Stale, tt.want.status, tt.want.stale)
+			}
+			switch {
+			case tt.want.detail == "" && result.Detail != nil:
+				t.Errorf("got detail %q, want none", *result.Detail)
+			case tt.want.detail != "" && (result.Detail == nil || !strings.Contains(*result.Detail, tt.want.detail)):
+				t.Errorf("got detail %v, want one containing %q", result.Detail, tt.want.detail)
+			}
+		})
+	}
+}
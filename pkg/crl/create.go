@@ -1,33 +1,45 @@
 package crl
 
 import (
+	"bytes"
 	"crypto"
 	"crypto/rand"
 	"crypto/x509"
+	"crypto/x509/pkix"
 	"fmt"
 	"math/big"
 	"time"
 
 	"github.com/goodieshq/revokr/pkg/util"
+	"github.com/rs/zerolog/log"
 )
 
 type CreateCRLParams struct {
-	SerialsInclude []string
-	SerialsIgnore  []string
-	Entries        []x509.RevocationListEntry
-	DigestPath     string
-	OutPath        string
-	TBS            bool
-	OutPEM         bool
-	CRLNumber      *big.Int
-	ThisUpdate     time.Time
-	NextUpdate     time.Time
+	SerialsInclude     []string
+	SerialsIgnore      []string
+	Revocations        []util.RevocationRecord
+	Entries            []x509.RevocationListEntry
+	DigestPath         string
+	DigestFormat       util.DigestFormat // defaults to base64
+	RequestPath        string            // signing request envelope written in TBS mode
+	OutPath            string
+	TBS                bool
+	OutPEM             bool
+	SignatureAlgorithm x509.SignatureAlgorithm // defaults to the algorithm matching the issuer key
+	CRLNumber          *big.Int
+	BaseCRLNumber      *big.Int // when set, a delta CRL is created against the base CRL with this number
+	FreshestCRL        []string // URIs of delta CRLs to advertise in a freshest CRL extension
+	ThisUpdate         time.Time
+	NextUpdate         time.Time
+
+	IssuingDistributionPoint *IssuingDistributionPoint
 }
 
 func CreateCRL(crt *x509.Certificate, key crypto.Signer, params *CreateCRLParams) error {
 	var err error
 
-	if !params.OutPEM && params.OutPath == "" {
+	requestOnly := params.TBS && params.RequestPath != "" && !params.OutPEM && params.OutPath == ""
+	if !params.OutPEM && params.OutPath == "" && !requestOnly {
 		return fmt.Errorf("output path must be specified when creating a DER format CRL")
 	}
 
@@ -45,33 +57,114 @@ func CreateCRL(crt *x509.Certificate, key crypto.Signer, params *CreateCRLParams
 		nextUpdate = params.NextUpdate
 	}
 
-	// Prepare revoked certificates list
+	sigAlg := params.SignatureAlgorithm
+	if sigAlg == x509.UnknownSignatureAlgorithm {
+		if sigAlg, err = util.DefaultSignatureAlgorithm(crt.PublicKey); err != nil {
+			return fmt.Errorf("failed to determine signature algorithm: %w", err)
+		}
+	}
+
+	// Prepare revoked certificates list, entries are keyed by certificate issuer and serial number
 	revokedCerts := params.Entries
-	serialsSeen := make(map[string]struct{})
+	entriesSeen := make(map[string]struct{})
 	for _, entry := range revokedCerts {
-		serialsSeen[entry.SerialNumber.Text(16)] = struct{}{}
+		entriesSeen[entryKey(entryIssuer(entry, crt.RawSubject), entry.SerialNumber.Text(16))] = struct{}{}
 	}
+	serialsIgnored := make(map[string]struct{})
 	for _, serial := range params.SerialsIgnore {
-		serialsSeen[serial] = struct{}{}
+		serialsIgnored[serial] = struct{}{}
+	}
+
+	for _, record := range params.Revocations {
+		issuer := crt.RawSubject
+		if record.Issuer != nil {
+			issuer = record.Issuer.RawSubject
+		}
+
+		key := entryKey(issuer, record.Serial)
+		_, seen := entriesSeen[key]
+		_, ignored := serialsIgnored[record.Serial]
+		if seen || ignored {
+			log.Warn().Str("serial", record.Serial).Msg("manifest serial is already revoked or ignored, skipping")
+			continue
+		}
+
+		entry, err := revocationEntry(record, thisUpdate, params.BaseCRLNumber != nil)
+		if err != nil {
+			return fmt.Errorf("failed to create revocation entry for serial %s: %w", record.Serial, err)
+		}
+		if !bytes.Equal(issuer, crt.RawSubject) {
+			entry.ExtraExtensions = append(entry.ExtraExtensions, certificateIssuerExtension(issuer))
+		}
+		revokedCerts = append(revokedCerts, entry)
+		entriesSeen[key] = struct{}{}
 	}
 
 	for _, serial := range params.SerialsInclude {
-		if _, ok := serialsSeen[serial]; !ok {
+		key := entryKey(crt.RawSubject, serial)
+		_, seen := entriesSeen[key]
+		_, ignored := serialsIgnored[serial]
+		if !seen && !ignored {
 			serialNum, _ := new(big.Int).SetString(serial, 16)
 			revokedCerts = append(revokedCerts, x509.RevocationListEntry{
 				SerialNumber:   serialNum,
 				RevocationTime: thisUpdate,
 			})
-			serialsSeen[serial] = struct{}{}
+			entriesSeen[key] = struct{}{}
+		}
+	}
+
+	// Only emit certificateIssuer where the issuer changes and mark the CRL as indirect if needed
+	revokedCerts, indirect := applyCertificateIssuers(revokedCerts, crt.RawSubject)
+	idp := params.IssuingDistributionPoint
+	if indirect && (idp == nil || !idp.IndirectCRL) {
+		log.Info().Msg("CRL contains entries of other issuers, marking it as an indirect CRL")
+		idp = &IssuingDistributionPoint{IndirectCRL: true}
+		if params.IssuingDistributionPoint != nil {
+			*idp = *params.IssuingDistributionPoint
+			idp.IndirectCRL = true
+		}
+	}
+
+	var extensions []pkix.Extension
+
+	if params.BaseCRLNumber != nil {
+		if params.CRLNumber.Cmp(params.BaseCRLNumber) <= 0 {
+			return fmt.Errorf("delta CRL number %s must be greater than the base CRL number %s", params.CRLNumber, params.BaseCRLNumber)
+		}
+		ext, err := deltaCRLIndicatorExtension(params.BaseCRLNumber)
+		if err != nil {
+			return err
 		}
+		extensions = append(extensions, ext)
+	}
+
+	if len(params.FreshestCRL) > 0 {
+		if params.BaseCRLNumber != nil {
+			return fmt.Errorf("the freshest CRL extension may not be used in a delta CRL")
+		}
+		ext, err := freshestCRLExtension(params.FreshestCRL)
+		if err != nil {
+			return err
+		}
+		extensions = append(extensions, ext)
+	}
+
+	if idp != nil {
+		ext, err := idp.extension()
+		if err != nil {
+			return fmt.Errorf("invalid issuing distribution point: %w", err)
+		}
+		extensions = append(extensions, ext)
 	}
 
 	crlTemplate := &x509.RevocationList{
 		Number:                    params.CRLNumber,
-		SignatureAlgorithm:        crt.SignatureAlgorithm,
+		SignatureAlgorithm:        sigAlg,
 		RevokedCertificateEntries: revokedCerts,
 		ThisUpdate:                thisUpdate,
 		NextUpdate:                nextUpdate,
+		ExtraExtensions:           extensions,
 	}
 
 	if params.TBS {
@@ -91,22 +184,112 @@ func CreateCRL(crt *x509.Certificate, key crypto.Signer, params *CreateCRLParams
 		return fmt.Errorf("failed to create CRL: %w", err)
 	}
 
+	// the TBS CRL is linted before it is extracted, its signature by the dummy signer is never checked
+	if err := lintBeforeWrite(crl); err != nil {
+		return err
+	}
+
 	if params.TBS {
 		crl, err = util.ExtractTBS(crl)
 		if err != nil {
 			return fmt.Errorf("failed to extract TBS from CRL: %w", err)
 		}
 
-		_, h, err := util.GetSignatureAlgAndHash(crt)
-		if err != nil {
-			return fmt.Errorf("failed to get hash for TBS CRL: %w", err)
+		if params.RequestPath != "" {
+			req, err := NewSigningRequest(crt, crl)
+			if err != nil {
+				return fmt.Errorf("failed to create signing request: %w", err)
+			}
+			if err := WriteSigningRequest(params.RequestPath, req); err != nil {
+				return err
+			}
+			log.Info().Str("path", params.RequestPath).Msgf("Wrote signing request for %s", req)
 		}
 
-		digest := h.Sum(crl)
-		if err := util.WriteDigest(params.DigestPath, digest); err != nil {
-			return fmt.Errorf("failed to write TBS CRL digest: %w", err)
+		if params.DigestPath != "" || params.RequestPath == "" {
+			if err := writeTBSDigest(crl, sigAlg, params); err != nil {
+				return err
+			}
+		}
+
+		if requestOnly {
+			return nil
 		}
 	}
 
 	return util.WriteCRL(params.OutPath, crl, params.OutPEM)
 }
+
+// writeTBSDigest writes the digest of a TBS CRL for an external signer, together with a description
+// of the signing parameters when it is written to a file.
+func writeTBSDigest(tbs []byte, sigAlg x509.SignatureAlgorithm, params *CreateCRLParams) error {
+	digestFormat := params.DigestFormat
+	if digestFormat == "" {
+		digestFormat = util.DigestFormatBase64
+	}
+
+	digest, desc, err := util.TBSDigest(tbs, sigAlg, digestFormat)
+	if err != nil {
+		return fmt.Errorf("failed to compute TBS CRL digest: %w", err)
+	}
+
+	if desc.Hash == "none" {
+		log.Info().Msg("Ed25519 signs the message itself, the digest output contains the full TBS CRL")
+	}
+	if desc.Padding != "none" {
+		log.Info().Str("algorithm", desc.SignatureAlgorithm).Str("padding", desc.Padding).Msg("external signer must sign the digest using this padding")
+	}
+
+	if err := util.WriteDigest(params.DigestPath, digest); err != nil {
+		return fmt.Errorf("failed to write TBS CRL digest: %w", err)
+	}
+
+	if params.DigestPath != "" {
+		if err := util.WriteDigestDescription(params.DigestPath, desc); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// revocationEntry converts a manifest record into a CRL entry, defaulting the revocation time to thisUpdate.
+// The removeFromCRL reason is only accepted when building a delta CRL.
+func revocationEntry(record util.RevocationRecord, thisUpdate time.Time, delta bool) (x509.RevocationListEntry, error) {
+	serialNum, ok := new(big.Int).SetString(record.Serial, 16)
+	if !ok {
+		return x509.RevocationListEntry{}, fmt.Errorf("invalid serial number")
+	}
+
+	if record.Reason == util.ReasonRemoveFromCRL && !delta {
+		return x509.RevocationListEntry{}, fmt.Errorf("reason %s is only valid in delta CRLs", util.ReasonString(record.Reason))
+	}
+
+	entry := x509.RevocationListEntry{
+		SerialNumber:   serialNum,
+		RevocationTime: record.RevocationTime,
+		ReasonCode:     record.Reason,
+	}
+	if entry.RevocationTime.IsZero() {
+		entry.RevocationTime = thisUpdate
+	}
+
+	if !record.InvalidityDate.IsZero() {
+		ext, err := invalidityDateExtension(record.InvalidityDate)
+		if err != nil {
+			return x509.RevocationListEntry{}, err
+		}
+		entry.ExtraExtensions = append(entry.ExtraExtensions, ext)
+	}
+
+	event := log.Info().Str("serial", record.Serial).Str("reason", util.ReasonString(record.Reason))
+	if record.Issuer != nil {
+		event = event.Str("issuer", record.Issuer.Subject.String())
+	}
+	if record.Comment != "" {
+		event = event.Str("comment", record.Comment)
+	}
+	event.Msg("revoking certificate")
+
+	return entry, nil
+}
@@ -0,0 +1,79 @@
+package crl
+
+import (
+	"crypto/x509"
+	"fmt"
+
+	"github.com/goodieshq/revokr/pkg/util"
+	"github.com/rs/zerolog/log"
+)
+
+// DeltaRevocations determines the entries of a delta CRL from the entries of its base CRL. New
+// revocations from the manifest and serials list are included unless the base CRL already revokes
+// them (a certificate on hold may still be revoked with a final reason). Released serials must be
+// on hold in the base CRL and are listed with the removeFromCRL reason. Entries are matched by
+// certificate issuer and serial number, so released entries of an indirect base CRL keep their
+// certificate issuer.
+func DeltaRevocations(crlIssuer []byte, base []x509.RevocationListEntry, revocations []util.RevocationRecord, serials, released []string) ([]util.RevocationRecord, error) {
+	baseReasons := make(map[string]int)
+	baseHeld := make(map[string][][]byte) // issuers of entries on hold by serial
+	for _, entry := range base {
+		issuer := entryIssuer(entry, crlIssuer)
+		serial := entry.SerialNumber.Text(16)
+		baseReasons[entryKey(issuer, serial)] = entry.ReasonCode
+		if entry.ReasonCode == util.ReasonCertificateHold {
+			baseHeld[serial] = append(baseHeld[serial], issuer)
+		}
+	}
+
+	records := append([]util.RevocationRecord{}, revocations...)
+	for _, serial := range serials {
+		records = append(records, util.RevocationRecord{Serial: serial})
+	}
+	for _, serial := range released {
+		record := util.RevocationRecord{Serial: serial, Reason: util.ReasonRemoveFromCRL}
+
+		// A released serial belongs to the CRL issuer unless only another issuer has it on hold
+		if baseReasons[entryKey(crlIssuer, serial)] != util.ReasonCertificateHold {
+			switch held := baseHeld[serial]; len(held) {
+			case 0:
+			case 1:
+				record.Issuer = issuerCertificate(held[0])
+			default:
+				return nil, fmt.Errorf("serial %s is on hold for several issuers in the base CRL, release it with a manifest naming its issuer", serial)
+			}
+		}
+		records = append(records, record)
+	}
+
+	var delta []util.RevocationRecord
+	seen := make(map[string]struct{})
+
+	for _, record := range records {
+		issuer := crlIssuer
+		if record.Issuer != nil {
+			issuer = record.Issuer.RawSubject
+		}
+		key := entryKey(issuer, record.Serial)
+
+		if _, ok := seen[key]; ok {
+			return nil, fmt.Errorf("serial %s is listed more than once for the delta CRL", record.Serial)
+		}
+
+		baseReason, inBase := baseReasons[key]
+
+		if record.Reason == util.ReasonRemoveFromCRL {
+			if !inBase || baseReason != util.ReasonCertificateHold {
+				return nil, fmt.Errorf("serial %s cannot be released because it is not on hold in the base CRL", record.Serial)
+			}
+		} else if inBase && (baseReason != util.ReasonCertificateHold || record.Reason == util.ReasonCertificateHold) {
+			log.Warn().Str("serial", record.Serial).Msg("serial is already revoked in the base CRL, skipping")
+			continue
+		}
+
+		seen[key] = struct{}{}
+		delta = append(delta, record)
+	}
+
+	return delta, nil
+}
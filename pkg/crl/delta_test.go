@@ -0,0 +1,111 @@
+package crl
+
+import (
+	"bytes"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/goodieshq/revokr/pkg/util"
+)
+
+func rawName(t *testing.T, cn string) []byte {
+	t.Helper()
+	der, err := asn1.Marshal(pkix.Name{CommonName: cn}.ToRDNSequence())
+	if err != nil {
+		t.Fatalf("failed to marshal name: %v", err)
+	}
+	return der
+}
+
+func TestDeltaRevocationsIndirect(t *testing.T) {
+	crlIssuer := rawName(t, "CRL Issuer")
+	otherIssuer := rawName(t, "Other CA")
+
+	// Both issuers revoked a certificate with serial 10, only the other issuer's is on hold
+	base := []x509.RevocationListEntry{
+		{SerialNumber: big.NewInt(0x10), ReasonCode: util.ReasonKeyCompromise},
+		{
+			SerialNumber:    big.NewInt(0x10),
+			ReasonCode:      util.ReasonCertificateHold,
+			ExtraExtensions: []pkix.Extension{certificateIssuerExtension(otherIssuer)},
+		},
+	}
+
+	delta, err := DeltaRevocations(crlIssuer, base, nil, nil, []string{"10"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(delta) != 1 {
+		t.Fatalf("got %d delta entries, want 1", len(delta))
+	}
+	if delta[0].Reason != util.ReasonRemoveFromCRL {
+		t.Errorf("reason = %d, want removeFromCRL", delta[0].Reason)
+	}
+	if delta[0].Issuer == nil || !bytes.Equal(delta[0].Issuer.RawSubject, otherIssuer) {
+		t.Errorf("released entry does not carry the certificate issuer of the held entry")
+	}
+	if delta[0].Issuer != nil && delta[0].Issuer.Subject.CommonName != "Other CA" {
+		t.Errorf("issuer subject = %q, want %q", delta[0].Issuer.Subject.CommonName, "Other CA")
+	}
+
+	// Revoking the CRL issuer's serial 10 again is skipped, it is not the entry on hold
+	delta, err = DeltaRevocations(crlIssuer, base, []util.RevocationRecord{{Serial: "10", Reason: util.ReasonSuperseded}}, nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(delta) != 0 {
+		t.Errorf("got %d delta entries, want the already revoked serial to be skipped", len(delta))
+	}
+}
+
+func TestDeltaRevocationsRelease(t *testing.T) {
+	crlIssuer := rawName(t, "CRL Issuer")
+	otherIssuer := rawName(t, "Other CA")
+	thirdIssuer := rawName(t, "Third CA")
+
+	base := []x509.RevocationListEntry{
+		{SerialNumber: big.NewInt(0x1), ReasonCode: util.ReasonCertificateHold},
+		{SerialNumber: big.NewInt(0x2), ReasonCode: util.ReasonKeyCompromise},
+		{
+			SerialNumber:    big.NewInt(0x3),
+			ReasonCode:      util.ReasonCertificateHold,
+			ExtraExtensions: []pkix.Extension{certificateIssuerExtension(otherIssuer)},
+		},
+		{
+			SerialNumber:    big.NewInt(0x3),
+			ReasonCode:      util.ReasonCertificateHold,
+			ExtraExtensions: []pkix.Extension{certificateIssuerExtension(thirdIssuer)},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		released []string
+		errText  string
+	}{
+		{name: "on hold", released: []string{"1"}},
+		{name: "not on hold", released: []string{"2"}, errText: "not on hold"},
+		{name: "not in base", released: []string{"4"}, errText: "not on hold"},
+		{name: "listed twice", released: []string{"1", "1"}, errText: "more than once"},
+		{name: "ambiguous issuer", released: []string{"3"}, errText: "several issuers"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := DeltaRevocations(crlIssuer, base, nil, nil, tt.released)
+			if tt.errText == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errText) {
+				t.Fatalf("error %v does not contain %q", err, tt.errText)
+			}
+		})
+	}
+}
@@ -0,0 +1,215 @@
+package crl
+
+import (
+	"bytes"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+var (
+	oidExtensionReasonCode        = asn1.ObjectIdentifier{2, 5, 29, 21}
+	oidExtensionInvalidityDate    = asn1.ObjectIdentifier{2, 5, 29, 24}
+	oidExtensionDeltaCRLIndicator = asn1.ObjectIdentifier{2, 5, 29, 27}
+	oidExtensionCertificateIssuer = asn1.ObjectIdentifier{2, 5, 29, 29}
+	oidExtensionFreshestCRL       = asn1.ObjectIdentifier{2, 5, 29, 46}
+)
+
+// distributionPointName is the DistributionPointName CHOICE from RFC 5280 section 4.2.1.13.
+// Only the fullName alternative is supported.
+type distributionPointName struct {
+	FullName []asn1.RawValue `asn1:"optional,tag:0"`
+}
+
+// distributionPoint is the DistributionPoint SEQUENCE from RFC 5280 section 4.2.1.13.
+type distributionPoint struct {
+	DistributionPoint distributionPointName `asn1:"optional,tag:0"`
+}
+
+// uriGeneralNames encodes each URI as a uniformResourceIdentifier GeneralName.
+func uriGeneralNames(uris []string) []asn1.RawValue {
+	names := make([]asn1.RawValue, 0, len(uris))
+	for _, uri := range uris {
+		names = append(names, asn1.RawValue{
+			Class: asn1.ClassContextSpecific,
+			Tag:   6, // uniformResourceIdentifier
+			Bytes: []byte(uri),
+		})
+	}
+	return names
+}
+
+// deltaCRLIndicatorExtension builds the critical delta CRL indicator extension (RFC 5280 section 5.2.4)
+// referencing the CRL number of the base CRL.
+func deltaCRLIndicatorExtension(baseNumber *big.Int) (pkix.Extension, error) {
+	value, err := asn1.Marshal(baseNumber)
+	if err != nil {
+		return pkix.Extension{}, fmt.Errorf("failed to marshal base CRL number: %w", err)
+	}
+	return pkix.Extension{
+		Id:       oidExtensionDeltaCRLIndicator,
+		Critical: true,
+		Value:    value,
+	}, nil
+}
+
+// freshestCRLExtension builds the freshest CRL extension (RFC 5280 section 5.2.6) pointing
+// relying parties at the delta CRLs published at the given URIs.
+func freshestCRLExtension(uris []string) (pkix.Extension, error) {
+	value, err := asn1.Marshal([]distributionPoint{{
+		DistributionPoint: distributionPointName{FullName: uriGeneralNames(uris)},
+	}})
+	if err != nil {
+		return pkix.Extension{}, fmt.Errorf("failed to marshal freshest CRL distribution point: %w", err)
+	}
+	return pkix.Extension{
+		Id:    oidExtensionFreshestCRL,
+		Value: value,
+	}, nil
+}
+
+// isDeltaCRL reports whether the CRL extensions contain a delta CRL indicator.
+func isDeltaCRL(exts []pkix.Extension) bool {
+	for _, ext := range exts {
+		if ext.Id.Equal(oidExtensionDeltaCRLIndicator) {
+			return true
+		}
+	}
+	return false
+}
+
+// DeltaBaseNumber returns the base CRL number of a delta CRL, or nil if the CRL is not a delta CRL.
+func DeltaBaseNumber(rl *x509.RevocationList) (*big.Int, error) {
+	for _, ext := range rl.Extensions {
+		if ext.Id.Equal(oidExtensionDeltaCRLIndicator) {
+			var base *big.Int
+			if _, err := asn1.Unmarshal(ext.Value, &base); err != nil {
+				return nil, fmt.Errorf("invalid delta CRL indicator: %w", err)
+			}
+			return base, nil
+		}
+	}
+	return nil, nil
+}
+
+// invalidityDateExtension builds the invalidity date CRL entry extension (RFC 5280 section 5.3.2).
+func invalidityDateExtension(t time.Time) (pkix.Extension, error) {
+	value, err := asn1.MarshalWithParams(t.UTC(), "generalized")
+	if err != nil {
+		return pkix.Extension{}, fmt.Errorf("failed to marshal invalidity date: %w", err)
+	}
+	return pkix.Extension{
+		Id:    oidExtensionInvalidityDate,
+		Value: value,
+	}, nil
+}
+
+// entryExtraExtensions returns the extensions of a parsed CRL entry that must be copied into
+// ExtraExtensions to survive re-encoding. The reason code is excluded because it is carried by
+// the ReasonCode field instead, and the certificate issuer because it depends on the order of
+// the entries in the new CRL (see applyCertificateIssuers).
+func entryExtraExtensions(exts []pkix.Extension) []pkix.Extension {
+	var extra []pkix.Extension
+	for _, ext := range exts {
+		if ext.Id.Equal(oidExtensionReasonCode) || ext.Id.Equal(oidExtensionCertificateIssuer) {
+			continue
+		}
+		extra = append(extra, ext)
+	}
+	return extra
+}
+
+// certificateIssuerExtension builds the critical certificate issuer CRL entry extension (RFC 5280
+// section 5.3.3) naming the DER encoded issuer as a directoryName.
+func certificateIssuerExtension(issuer []byte) pkix.Extension {
+	// GeneralNames with a single [4] directoryName, the length cannot fail to marshal
+	value, _ := asn1.Marshal([]asn1.RawValue{{
+		Class:      asn1.ClassContextSpecific,
+		Tag:        4, // directoryName
+		IsCompound: true,
+		Bytes:      issuer,
+	}})
+	return pkix.Extension{
+		Id:       oidExtensionCertificateIssuer,
+		Critical: true,
+		Value:    value,
+	}
+}
+
+// parseCertificateIssuer returns the DER encoded directoryName of a certificate issuer extension.
+func parseCertificateIssuer(value []byte) ([]byte, error) {
+	var names []asn1.RawValue
+	if _, err := asn1.Unmarshal(value, &names); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal certificate issuer: %w", err)
+	}
+	for _, name := range names {
+		if name.Class == asn1.ClassContextSpecific && name.Tag == 4 {
+			return name.Bytes, nil
+		}
+	}
+	return nil, fmt.Errorf("certificate issuer has no directory name")
+}
+
+// entryIssuer returns the DER encoded issuer of a CRL entry, taken from its certificate issuer
+// extension or defaulting to the given issuer.
+func entryIssuer(entry x509.RevocationListEntry, defaultIssuer []byte) []byte {
+	for _, ext := range entry.ExtraExtensions {
+		if ext.Id.Equal(oidExtensionCertificateIssuer) {
+			if issuer, err := parseCertificateIssuer(ext.Value); err == nil {
+				return issuer
+			}
+		}
+	}
+	return defaultIssuer
+}
+
+// issuerCertificate returns a stand-in certificate for a DER encoded issuer name taken from a CRL,
+// for revocation records of issuers whose certificate is not at hand.
+func issuerCertificate(issuer []byte) *x509.Certificate {
+	crt := &x509.Certificate{RawSubject: issuer}
+	var rdns pkix.RDNSequence
+	if _, err := asn1.Unmarshal(issuer, &rdns); err == nil {
+		crt.Subject.FillFromRDNSequence(&rdns)
+	}
+	return crt
+}
+
+// entryKey identifies a CRL entry by its certificate issuer and serial number.
+func entryKey(issuer []byte, serial string) string {
+	return string(issuer) + "/" + serial
+}
+
+// applyCertificateIssuers rewrites the certificate issuer extensions of the entries so that one is
+// only present where the issuer differs from the previous entry, as described in RFC 5280 section
+// 5.3.3. Entries without the extension belong to crlIssuer. Reports whether any entry belongs to
+// another issuer, which requires the CRL to be an indirect CRL.
+func applyCertificateIssuers(entries []x509.RevocationListEntry, crlIssuer []byte) ([]x509.RevocationListEntry, bool) {
+	current := crlIssuer
+	indirect := false
+
+	for i, entry := range entries {
+		issuer := entryIssuer(entry, crlIssuer)
+
+		var exts []pkix.Extension
+		for _, ext := range entry.ExtraExtensions {
+			if !ext.Id.Equal(oidExtensionCertificateIssuer) {
+				exts = append(exts, ext)
+			}
+		}
+
+		if !bytes.Equal(issuer, current) {
+			exts = append(exts, certificateIssuerExtension(issuer))
+			current = issuer
+		}
+		if !bytes.Equal(issuer, crlIssuer) {
+			indirect = true
+		}
+
+		entries[i].ExtraExtensions = exts
+	}
+
+	return entries, indirect
+}
@@ -1,7 +1,9 @@
 package crl
 
 import (
+	"bytes"
 	"crypto/x509"
+	"fmt"
 	"math/big"
 
 	"github.com/goodieshq/revokr/pkg/util"
@@ -10,17 +12,20 @@ import (
 
 // ExtractRevocationEntries reads revocation entries from the provided CRL files,
 // ignoring any serial numbers specified in the ignore list. It returns the highest
-// CRL number found in the paths and a deduplicated list of revocation entries.
+// CRL number found in the paths and a list of revocation entries deduplicated by
+// certificate issuer and serial number. Entries that belong to another issuer than
+// the CRL issuer carry an explicit certificate issuer extension.
 // Returns -1 as crlNumber if no valid CRL number is found.
 func ExtractRevocationEntries(ignore []string, paths ...string) (*big.Int, []x509.RevocationListEntry, error) {
 	// Initialize crlNumber to -1 to indicate no valid CRL number found yet
 	var crlNumber = new(big.Int).SetInt64(-1)
 
-	// Use a map to track seen serial numbers for deduplication
-	serialsSeen := make(map[string]struct{})
+	// Track ignored serial numbers and seen (issuer, serial) pairs for deduplication
+	serialsIgnored := make(map[string]struct{})
 	for _, serial := range ignore {
-		serialsSeen[serial] = struct{}{}
+		serialsIgnored[serial] = struct{}{}
 	}
+	entriesSeen := make(map[string]struct{})
 
 	// Collect revocation entries from all provided CRL files
 	var entries []x509.RevocationListEntry
@@ -41,16 +46,43 @@ func ExtractRevocationEntries(ignore []string, paths ...string) (*big.Int, []x50
 			continue
 		}
 
-		// Update the highest CRL number found
-		if crl.Number.Cmp(crlNumber) > 0 {
+		// Delta CRLs only hold changes since their base and cannot be extended
+		if isDeltaCRL(crl.Extensions) {
+			log.Warn().Str("path", path).Msg("CRL is a delta CRL, skipping")
+			continue
+		}
+
+		// Update the highest CRL number found, CRLs without a CRL number extension have none
+		if crl.Number != nil && crl.Number.Cmp(crlNumber) > 0 {
 			crlNumber = crl.Number
 		}
 
-		// Add revocation entries, deduplicating by serial number
+		// Add revocation entries, deduplicating by certificate issuer and serial number. Entries of
+		// indirect CRLs inherit the issuer of the previous entry unless they name one themselves.
+		issuer := crl.RawIssuer
 		for _, entry := range crl.RevokedCertificateEntries {
+			for _, ext := range entry.Extensions {
+				if ext.Id.Equal(oidExtensionCertificateIssuer) {
+					if issuer, err = parseCertificateIssuer(ext.Value); err != nil {
+						return nil, nil, fmt.Errorf("invalid certificate issuer entry extension in %q: %w", path, err)
+					}
+				}
+			}
+
 			serial := entry.SerialNumber.Text(16)
-			if _, ok := serialsSeen[serial]; !ok {
-				serialsSeen[serial] = struct{}{}
+			if _, ok := serialsIgnored[serial]; ok {
+				continue
+			}
+
+			key := entryKey(issuer, serial)
+			if _, ok := entriesSeen[key]; !ok {
+				entriesSeen[key] = struct{}{}
+				// carry entry extensions (e.g. invalidity date) over to the new CRL and name the
+				// issuer explicitly on entries that do not belong to the CRL issuer
+				entry.ExtraExtensions = entryExtraExtensions(entry.Extensions)
+				if !bytes.Equal(issuer, crl.RawIssuer) {
+					entry.ExtraExtensions = append(entry.ExtraExtensions, certificateIssuerExtension(issuer))
+				}
 				entries = append(entries, entry)
 			}
 		}
@@ -0,0 +1,195 @@
+package crl
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"slices"
+
+	"github.com/goodieshq/revokr/pkg/util"
+	"github.com/rs/zerolog/log"
+)
+
+var oidExtensionIssuingDistributionPoint = asn1.ObjectIdentifier{2, 5, 29, 28}
+
+// IssuingDistributionPoint describes the scope of a CRL (RFC 5280 section 5.2.5).
+type IssuingDistributionPoint struct {
+	URIs                  []string // fullName of the distribution point
+	OnlyContainsUserCerts bool
+	OnlyContainsCACerts   bool
+	OnlySomeReasons       []int // CRL reason codes covered by the CRL, empty means all reasons
+	IndirectCRL           bool
+}
+
+// issuingDistributionPoint is the ASN.1 structure of the issuing distribution point extension.
+type issuingDistributionPoint struct {
+	DistributionPoint          distributionPointName `asn1:"optional,tag:0"`
+	OnlyContainsUserCerts      bool                  `asn1:"optional,tag:1"`
+	OnlyContainsCACerts        bool                  `asn1:"optional,tag:2"`
+	OnlySomeReasons            asn1.BitString        `asn1:"optional,tag:3"`
+	IndirectCRL                bool                  `asn1:"optional,tag:4"`
+	OnlyContainsAttributeCerts bool                  `asn1:"optional,tag:5"`
+}
+
+// reasonFlagBits maps CRL reason codes to their bit in the ReasonFlags BIT STRING.
+var reasonFlagBits = map[int]int{
+	util.ReasonKeyCompromise:        1,
+	util.ReasonCACompromise:         2,
+	util.ReasonAffiliationChanged:   3,
+	util.ReasonSuperseded:           4,
+	util.ReasonCessationOfOperation: 5,
+	util.ReasonCertificateHold:      6,
+	util.ReasonPrivilegeWithdrawn:   7,
+	util.ReasonAACompromise:         8,
+}
+
+// Validate checks the combination of fields is allowed by RFC 5280.
+func (idp *IssuingDistributionPoint) Validate() error {
+	if idp.OnlyContainsUserCerts && idp.OnlyContainsCACerts {
+		return fmt.Errorf("onlyContainsUserCerts and onlyContainsCACerts are mutually exclusive")
+	}
+	if len(idp.URIs) == 0 && !idp.OnlyContainsUserCerts && !idp.OnlyContainsCACerts && len(idp.OnlySomeReasons) == 0 && !idp.IndirectCRL {
+		return fmt.Errorf("issuing distribution point must have at least one field set")
+	}
+	for _, reason := range idp.OnlySomeReasons {
+		if _, ok := reasonFlagBits[reason]; !ok {
+			return fmt.Errorf("reason %s cannot be used in onlySomeReasons", util.ReasonString(reason))
+		}
+	}
+	return nil
+}
+
+// extension encodes the issuing distribution point as a critical CRL extension.
+func (idp *IssuingDistributionPoint) extension() (pkix.Extension, error) {
+	if err := idp.Validate(); err != nil {
+		return pkix.Extension{}, err
+	}
+
+	raw := issuingDistributionPoint{
+		OnlyContainsUserCerts: idp.OnlyContainsUserCerts,
+		OnlyContainsCACerts:   idp.OnlyContainsCACerts,
+		IndirectCRL:           idp.IndirectCRL,
+	}
+
+	if len(idp.URIs) > 0 {
+		raw.DistributionPoint = distributionPointName{FullName: uriGeneralNames(idp.URIs)}
+	}
+
+	if len(idp.OnlySomeReasons) > 0 {
+		// named bit lists are encoded without trailing zero bits
+		var bitLength int
+		for _, reason := range idp.OnlySomeReasons {
+			bitLength = max(bitLength, reasonFlagBits[reason]+1)
+		}
+		raw.OnlySomeReasons = asn1.BitString{Bytes: make([]byte, (bitLength+7)/8), BitLength: bitLength}
+		for _, reason := range idp.OnlySomeReasons {
+			bit := reasonFlagBits[reason]
+			raw.OnlySomeReasons.Bytes[bit/8] |= 0x80 >> (bit % 8)
+		}
+	}
+
+	value, err := asn1.Marshal(raw)
+	if err != nil {
+		return pkix.Extension{}, fmt.Errorf("failed to marshal issuing distribution point: %w", err)
+	}
+
+	return pkix.Extension{
+		Id:       oidExtensionIssuingDistributionPoint,
+		Critical: true,
+		Value:    value,
+	}, nil
+}
+
+// parseIssuingDistributionPoint decodes the value of an issuing distribution point extension.
+func parseIssuingDistributionPoint(value []byte) (*IssuingDistributionPoint, error) {
+	var raw issuingDistributionPoint
+	rest, err := asn1.Unmarshal(value, &raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal issuing distribution point: %w", err)
+	}
+	if len(rest) > 0 {
+		return nil, fmt.Errorf("trailing data after issuing distribution point")
+	}
+	if raw.OnlyContainsAttributeCerts {
+		return nil, fmt.Errorf("attribute certificate CRLs are not supported")
+	}
+
+	idp := &IssuingDistributionPoint{
+		OnlyContainsUserCerts: raw.OnlyContainsUserCerts,
+		OnlyContainsCACerts:   raw.OnlyContainsCACerts,
+		IndirectCRL:           raw.IndirectCRL,
+	}
+
+	for _, name := range raw.DistributionPoint.FullName {
+		if name.Class == asn1.ClassContextSpecific && name.Tag == 6 {
+			idp.URIs = append(idp.URIs, string(name.Bytes))
+		} else {
+			log.Warn().Int("tag", name.Tag).Msg("unsupported general name in issuing distribution point, dropping")
+		}
+	}
+
+	for reason, bit := range reasonFlagBits {
+		if raw.OnlySomeReasons.At(bit) == 1 {
+			idp.OnlySomeReasons = append(idp.OnlySomeReasons, reason)
+		}
+	}
+	slices.Sort(idp.OnlySomeReasons)
+
+	return idp, nil
+}
+
+// findIssuingDistributionPoint returns the issuing distribution point in the CRL extensions, or nil if absent.
+func findIssuingDistributionPoint(exts []pkix.Extension) (*IssuingDistributionPoint, error) {
+	for _, ext := range exts {
+		if ext.Id.Equal(oidExtensionIssuingDistributionPoint) {
+			return parseIssuingDistributionPoint(ext.Value)
+		}
+	}
+	return nil, nil
+}
+
+// ExtractIssuingDistributionPoint returns the issuing distribution point of the first provided CRL
+// that has one, so that the scope of an extended CRL is carried forward. Returns nil if none of
+// the CRLs has an issuing distribution point.
+func ExtractIssuingDistributionPoint(paths ...string) (*IssuingDistributionPoint, error) {
+	var idp *IssuingDistributionPoint
+	var idpPath string
+
+	for _, path := range paths {
+		block, err := util.TryParsePEM(path)
+		if err != nil {
+			continue // already reported by ExtractRevocationEntries
+		}
+
+		crl, err := x509.ParseRevocationList(block.Bytes)
+		if err != nil {
+			continue
+		}
+
+		found, err := findIssuingDistributionPoint(crl.Extensions)
+		if err != nil {
+			return nil, fmt.Errorf("invalid issuing distribution point in %q: %w", path, err)
+		}
+		if found == nil {
+			continue
+		}
+
+		if idp == nil {
+			idp, idpPath = found, path
+		} else if !idp.Equal(found) {
+			log.Warn().Str("path", path).Str("using", idpPath).Msg("CRLs have different issuing distribution points")
+		}
+	}
+
+	return idp, nil
+}
+
+// Equal reports whether two issuing distribution points describe the same scope.
+func (idp *IssuingDistributionPoint) Equal(other *IssuingDistributionPoint) bool {
+	return slices.Equal(idp.URIs, other.URIs) &&
+		idp.OnlyContainsUserCerts == other.OnlyContainsUserCerts &&
+		idp.OnlyContainsCACerts == other.OnlyContainsCACerts &&
+		slices.Equal(idp.OnlySomeReasons, other.OnlySomeReasons) &&
+		idp.IndirectCRL == other.IndirectCRL
+}
@@ -0,0 +1,445 @@
+package crl
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/goodieshq/revokr/pkg/util"
+	"golang.org/x/crypto/cryptobyte"
+	cryptobyte_asn1 "golang.org/x/crypto/cryptobyte/asn1"
+)
+
+// InspectionFormat identifies the JSON schema of Inspection. It changes whenever a field is renamed or
+// removed, so scripts can rely on the fields present for a given format.
+const InspectionFormat = "revokr-inspect/v1"
+
+var (
+	oidExtensionAuthorityKeyID = asn1.ObjectIdentifier{2, 5, 29, 35}
+	oidExtensionCRLNumber      = asn1.ObjectIdentifier{2, 5, 29, 20}
+)
+
+// extensionNames are the names used for known CRL and CRL entry extensions, following OpenSSL.
+var extensionNames = map[string]string{
+	oidExtensionAuthorityKeyID.String():           "X509v3 Authority Key Identifier",
+	oidExtensionCRLNumber.String():                "X509v3 CRL Number",
+	oidExtensionDeltaCRLIndicator.String():        "X509v3 Delta CRL Indicator",
+	oidExtensionIssuingDistributionPoint.String(): "X509v3 Issuing Distribution Point",
+	oidExtensionFreshestCRL.String():              "X509v3 Freshest CRL",
+	oidExtensionReasonCode.String():               "X509v3 CRL Reason Code",
+	oidExtensionInvalidityDate.String():           "Invalidity Date",
+	oidExtensionCertificateIssuer.String():        "X509v3 Certificate Issuer",
+	"2.5.29.60":                                   "Expired Certs On CRL",
+	"1.3.6.1.5.5.7.1.1":                           "Authority Information Access",
+}
+
+// Inspection is the decoded content of a CRL or TBS CRL. Its JSON encoding is stable, see
+// InspectionFormat; optional values are null rather than omitted.
+type Inspection struct {
+	Format                   string                    `json:"format"`
+	Kind                     string                    `json:"kind"` // "crl" or "tbs"
+	SHA256                   string                    `json:"sha256"`
+	Version                  int                       `json:"version"`
+	SignatureAlgorithm       string                    `json:"signature_algorithm"`
+	Issuer                   string                    `json:"issuer"`
+	ThisUpdate               time.Time                 `json:"this_update"`
+	NextUpdate               *time.Time                `json:"next_update"`
+	CRLNumber                *string                   `json:"crl_number"`
+	DeltaBaseCRLNumber       *string                   `json:"delta_base_crl_number"`
+	AuthorityKeyID           *string                   `json:"authority_key_id"`
+	IssuingDistributionPoint *InspectedIDP             `json:"issuing_distribution_point"`
+	FreshestCRL              []string                  `json:"freshest_crl"`
+	Extensions               []InspectedExtension      `json:"extensions"`
+	Entries                  []InspectedRevocationItem `json:"entries"`
+	Signature                *string                   `json:"signature"`
+}
+
+// InspectedIDP is the decoded issuing distribution point extension.
+type InspectedIDP struct {
+	URIs                  []string `json:"uris"`
+	OnlyContainsUserCerts bool     `json:"only_contains_user_certs"`
+	OnlyContainsCACerts   bool     `json:"only_contains_ca_certs"`
+	OnlySomeReasons       []string `json:"only_some_reasons"`
+	IndirectCRL           bool     `json:"indirect_crl"`
+}
+
+// InspectedExtension is a CRL or CRL entry extension with its value decoded to text, or hex for
+// unknown extensions.
+type InspectedExtension struct {
+	OID      string `json:"oid"`
+	Name     string `json:"name"`
+	Critical bool   `json:"critical"`
+	Value    string `json:"value"`
+}
+
+// InspectedRevocationItem is a decoded CRL entry. Serial is uppercase hex with an even number of digits,
+// as printed by OpenSSL. CertificateIssuer is the issuer the entry applies to
+// in an indirect CRL, carried forward from earlier entries as described in RFC 5280 section 5.3.3, or
+// null for entries of the CRL issuer.
+type InspectedRevocationItem struct {
+	Serial            string               `json:"serial"`
+	RevocationTime    time.Time            `json:"revocation_time"`
+	Reason            *string              `json:"reason"`
+	ReasonCode        *int                 `json:"reason_code"`
+	InvalidityDate    *time.Time           `json:"invalidity_date"`
+	CertificateIssuer *string              `json:"certificate_issuer"`
+	Extensions        []InspectedExtension `json:"extensions"`
+}
+
+// InspectFile decodes the CRL, TBS CRL or signing request at path.
+func InspectFile(path string) (*Inspection, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	if IsSigningRequest(data) {
+		req, err := ReadSigningRequest(path)
+		if err != nil {
+			return nil, err
+		}
+		return Inspect(req.TBS)
+	}
+
+	block, err := util.TryParsePEM(path)
+	if err != nil {
+		return nil, err
+	}
+	return Inspect(block.Bytes)
+}
+
+// Inspect decodes a DER encoded CRL or TBS CRL.
+func Inspect(der []byte) (*Inspection, error) {
+	sum := sha256.Sum256(der)
+	in := &Inspection{
+		Format:      InspectionFormat,
+		Kind:        "crl",
+		SHA256:      hex.EncodeToString(sum[:]),
+		FreshestCRL: []string{},
+		Extensions:  []InspectedExtension{},
+		Entries:     []InspectedRevocationItem{},
+	}
+
+	rl, err := x509.ParseRevocationList(der)
+	if err != nil {
+		var tbsErr error
+		if rl, tbsErr = util.ParseTBS(der); tbsErr != nil {
+			return nil, fmt.Errorf("not a CRL or TBS CRL: %w", err)
+		}
+		in.Kind = "tbs"
+	} else {
+		signature := hex.EncodeToString(rl.Signature)
+		in.Signature = &signature
+	}
+
+	in.Version = tbsVersion(rl.RawTBSRevocationList)
+	in.SignatureAlgorithm = rl.SignatureAlgorithm.String()
+	in.Issuer = rl.Issuer.String()
+	in.ThisUpdate = rl.ThisUpdate.UTC()
+	if !rl.NextUpdate.IsZero() {
+		next := rl.NextUpdate.UTC()
+		in.NextUpdate = &next
+	}
+
+	for _, ext := range rl.Extensions {
+		in.Extensions = append(in.Extensions, inspectExtension(ext))
+
+		switch {
+		case ext.Id.Equal(oidExtensionCRLNumber) && rl.Number != nil:
+			number := rl.Number.String()
+			in.CRLNumber = &number
+		case ext.Id.Equal(oidExtensionDeltaCRLIndicator):
+			if base, err := DeltaBaseNumber(rl); err == nil && base != nil {
+				number := base.String()
+				in.DeltaBaseCRLNumber = &number
+			}
+		case ext.Id.Equal(oidExtensionAuthorityKeyID) && len(rl.AuthorityKeyId) > 0:
+			aki := colonHex(rl.AuthorityKeyId)
+			in.AuthorityKeyID = &aki
+		case ext.Id.Equal(oidExtensionIssuingDistributionPoint):
+			if idp, err := parseIssuingDistributionPoint(ext.Value); err == nil {
+				in.IssuingDistributionPoint = inspectIDP(idp)
+			}
+		case ext.Id.Equal(oidExtensionFreshestCRL):
+			if uris, err := parseDistributionPointURIs(ext.Value); err == nil {
+				in.FreshestCRL = uris
+			}
+		}
+	}
+
+	var issuer *string
+	for _, entry := range rl.RevokedCertificateEntries {
+		item := InspectedRevocationItem{
+			Serial:         opensslSerial(entry.SerialNumber),
+			RevocationTime: entry.RevocationTime.UTC(),
+			Extensions:     []InspectedExtension{},
+		}
+
+		for _, ext := range entry.Extensions {
+			item.Extensions = append(item.Extensions, inspectExtension(ext))
+
+			switch {
+			case ext.Id.Equal(oidExtensionReasonCode):
+				code := entry.ReasonCode
+				reason := util.ReasonString(code)
+				item.ReasonCode, item.Reason = &code, &reason
+			case ext.Id.Equal(oidExtensionInvalidityDate):
+				var t time.Time
+				if _, err := asn1.Unmarshal(ext.Value, &t); err == nil {
+					t = t.UTC()
+					item.InvalidityDate = &t
+				}
+			case ext.Id.Equal(oidExtensionCertificateIssuer):
+				if name, err := certificateIssuerName(ext.Value); err == nil {
+					issuer = &name
+					if name == in.Issuer {
+						issuer = nil
+					}
+				}
+			}
+		}
+		item.CertificateIssuer = issuer
+
+		in.Entries = append(in.Entries, item)
+	}
+
+	return in, nil
+}
+
+// tbsVersion returns the CRL version encoded in a TBS CRL, 1 when the optional version is absent.
+func tbsVersion(tbs []byte) int {
+	input := cryptobyte.String(tbs)
+	var inner cryptobyte.String
+	var version int
+	if !input.ReadASN1(&inner, cryptobyte_asn1.SEQUENCE) || !inner.PeekASN1Tag(cryptobyte_asn1.INTEGER) ||
+		!inner.ReadASN1Integer(&version) {
+		return 1
+	}
+	return version + 1
+}
+
+func inspectIDP(idp *IssuingDistributionPoint) *InspectedIDP {
+	out := &InspectedIDP{
+		URIs:                  idp.URIs,
+		OnlyContainsUserCerts: idp.OnlyContainsUserCerts,
+		OnlyContainsCACerts:   idp.OnlyContainsCACerts,
+		OnlySomeReasons:       []string{},
+		IndirectCRL:           idp.IndirectCRL,
+	}
+	if out.URIs == nil {
+		out.URIs = []string{}
+	}
+	for _, reason := range idp.OnlySomeReasons {
+		out.OnlySomeReasons = append(out.OnlySomeReasons, util.ReasonString(reason))
+	}
+	return out
+}
+
+// parseDistributionPointURIs returns the URIs of the fullName of every distribution point in a
+// CRLDistributionPoints or FreshestCRL extension.
+func parseDistributionPointURIs(value []byte) ([]string, error) {
+	var points []distributionPoint
+	if _, err := asn1.Unmarshal(value, &points); err != nil {
+		return nil, err
+	}
+	uris := []string{}
+	for _, point := range points {
+		for _, name := range point.DistributionPoint.FullName {
+			if name.Class == asn1.ClassContextSpecific && name.Tag == 6 {
+				uris = append(uris, string(name.Bytes))
+			}
+		}
+	}
+	return uris, nil
+}
+
+// certificateIssuerName returns the directoryName of a certificate issuer extension as a string.
+func certificateIssuerName(value []byte) (string, error) {
+	der, err := parseCertificateIssuer(value)
+	if err != nil {
+		return "", err
+	}
+	var rdns pkix.RDNSequence
+	if _, err := asn1.Unmarshal(der, &rdns); err != nil {
+		return "", err
+	}
+	var name pkix.Name
+	name.FillFromRDNSequence(&rdns)
+	return name.String(), nil
+}
+
+// inspectExtension decodes the value of a known extension to text, falling back to hex.
+func inspectExtension(ext pkix.Extension) InspectedExtension {
+	out := InspectedExtension{
+		OID:      ext.Id.String(),
+		Name:     extensionNames[ext.Id.String()],
+		Critical: ext.Critical,
+		Value:    colonHex(ext.Value),
+	}
+	if out.Name == "" {
+		out.Name = ext.Id.String()
+	}
+
+	switch {
+	case ext.Id.Equal(oidExtensionAuthorityKeyID):
+		var aki struct {
+			KeyID []byte `asn1:"optional,tag:0"`
+		}
+		if _, err := asn1.Unmarshal(ext.Value, &aki); err == nil {
+			out.Value = "keyid:" + colonHex(aki.KeyID)
+		}
+	case ext.Id.Equal(oidExtensionCRLNumber), ext.Id.Equal(oidExtensionDeltaCRLIndicator):
+		var number *big.Int
+		if _, err := asn1.Unmarshal(ext.Value, &number); err == nil {
+			out.Value = number.String()
+		}
+	case ext.Id.Equal(oidExtensionIssuingDistributionPoint):
+		if idp, err := parseIssuingDistributionPoint(ext.Value); err == nil {
+			var parts []string
+			for _, uri := range idp.URIs {
+				parts = append(parts, "URI:"+uri)
+			}
+			if idp.OnlyContainsUserCerts {
+				parts = append(parts, "Only User Certificates")
+			}
+			if idp.OnlyContainsCACerts {
+				parts = append(parts, "Only CA Certificates")
+			}
+			if len(idp.OnlySomeReasons) > 0 {
+				var reasons []string
+				for _, reason := range idp.OnlySomeReasons {
+					reasons = append(reasons, util.ReasonString(reason))
+				}
+				parts = append(parts, "Only Some Reasons: "+strings.Join(reasons, ", "))
+			}
+			if idp.IndirectCRL {
+				parts = append(parts, "Indirect CRL")
+			}
+			out.Value = strings.Join(parts, "\n")
+		}
+	case ext.Id.Equal(oidExtensionFreshestCRL):
+		if uris, err := parseDistributionPointURIs(ext.Value); err == nil {
+			parts := make([]string, len(uris))
+			for i, uri := range uris {
+				parts[i] = "URI:" + uri
+			}
+			out.Value = strings.Join(parts, "\n")
+		}
+	case ext.Id.Equal(oidExtensionReasonCode):
+		var code asn1.Enumerated
+		if _, err := asn1.Unmarshal(ext.Value, &code); err == nil {
+			out.Value = util.ReasonString(int(code))
+		}
+	case ext.Id.Equal(oidExtensionInvalidityDate):
+		var t time.Time
+		if _, err := asn1.Unmarshal(ext.Value, &t); err == nil {
+			out.Value = t.UTC().Format(time.RFC3339)
+		}
+	case ext.Id.Equal(oidExtensionCertificateIssuer):
+		if name, err := certificateIssuerName(ext.Value); err == nil {
+			out.Value = "DirName:" + name
+		}
+	}
+
+	return out
+}
+
+// colonHex formats bytes as uppercase colon separated hex, as OpenSSL does for key identifiers.
+func colonHex(b []byte) string {
+	parts := make([]string, len(b))
+	for i, c := range b {
+		parts[i] = fmt.Sprintf("%02X", c)
+	}
+	return strings.Join(parts, ":")
+}
+
+// opensslSerial formats a serial number the way OpenSSL prints it, in uppercase hex of whole octets.
+func opensslSerial(serial *big.Int) string {
+	b := serial.Bytes()
+	if len(b) == 0 {
+		b = []byte{0}
+	}
+	s := strings.ToUpper(hex.EncodeToString(b))
+	if serial.Sign() < 0 {
+		s = "-" + s
+	}
+	return s
+}
+
+// opensslTime formats a time the way OpenSSL prints CRL dates.
+func opensslTime(t time.Time) string {
+	return t.UTC().Format("Jan _2 15:04:05 2006 GMT")
+}
+
+// WriteText writes the inspection in a layout resembling "openssl crl -text".
+func (in *Inspection) WriteText(w io.Writer) error {
+	var b strings.Builder
+
+	title := "Certificate Revocation List (CRL)"
+	if in.Kind == "tbs" {
+		title = "To Be Signed Certificate Revocation List (TBS CRL)"
+	}
+	fmt.Fprintf(&b, "%s:\n", title)
+	fmt.Fprintf(&b, "        Version %d (0x%x)\n", in.Version, in.Version-1)
+	fmt.Fprintf(&b, "        Signature Algorithm: %s\n", in.SignatureAlgorithm)
+	fmt.Fprintf(&b, "        Issuer: %s\n", in.Issuer)
+	fmt.Fprintf(&b, "        Last Update: %s\n", opensslTime(in.ThisUpdate))
+	if in.NextUpdate != nil {
+		fmt.Fprintf(&b, "        Next Update: %s\n", opensslTime(*in.NextUpdate))
+	} else {
+		fmt.Fprintf(&b, "        Next Update: NONE\n")
+	}
+	if len(in.Extensions) > 0 {
+		fmt.Fprintf(&b, "        CRL extensions:\n")
+		writeExtensionsText(&b, in.Extensions, "            ")
+	}
+
+	if len(in.Entries) == 0 {
+		fmt.Fprintf(&b, "No Revoked Certificates.\n")
+	} else {
+		fmt.Fprintf(&b, "Revoked Certificates:\n")
+	}
+	for _, entry := range in.Entries {
+		fmt.Fprintf(&b, "    Serial Number: %s\n", entry.Serial)
+		fmt.Fprintf(&b, "        Revocation Date: %s\n", opensslTime(entry.RevocationTime))
+		if len(entry.Extensions) > 0 {
+			fmt.Fprintf(&b, "        CRL entry extensions:\n")
+			writeExtensionsText(&b, entry.Extensions, "            ")
+		}
+	}
+
+	if in.Signature != nil {
+		fmt.Fprintf(&b, "    Signature Algorithm: %s\n", in.SignatureAlgorithm)
+		fmt.Fprintf(&b, "    Signature Value:\n")
+		sig, _ := hex.DecodeString(*in.Signature)
+		for len(sig) > 0 {
+			n := min(len(sig), 18)
+			fmt.Fprintf(&b, "        %s\n", strings.ToLower(colonHex(sig[:n])))
+			sig = sig[n:]
+		}
+	}
+	fmt.Fprintf(&b, "SHA-256 Fingerprint: %s\n", strings.ToUpper(in.SHA256))
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeExtensionsText(b *strings.Builder, exts []InspectedExtension, indent string) {
+	for _, ext := range exts {
+		critical := ""
+		if ext.Critical {
+			critical = " critical"
+		}
+		fmt.Fprintf(b, "%s%s:%s\n", indent, ext.Name, critical)
+		for _, line := range strings.Split(ext.Value, "\n") {
+			fmt.Fprintf(b, "%s    %s\n", indent, line)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package crl
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// testdata/inspect.crl is a complete indirect CRL of testdata/inspect-ca.crt with an issuing distribution
+// point and a freshest CRL. Its entries switch to another certificate issuer and back, so the issuer is
+// carried forward to the entry in between. testdata/inspect-delta.crl is a delta CRL against it. Both
+// are signed with Ed25519, so they are reproducible. The golden files lock the revokr-inspect/v1 JSON
+// schema and the text layout; run "go test -run TestInspectGolden -update" after intended changes.
+
+func TestInspectGolden(t *testing.T) {
+	for _, file := range []string{"inspect.crl", "inspect-delta.crl"} {
+		t.Run(file, func(t *testing.T) {
+			in, err := InspectFile(filepath.Join("testdata", file))
+			if err != nil {
+				t.Fatalf("InspectFile failed: %v", err)
+			}
+
+			var text bytes.Buffer
+			if err := in.WriteText(&text); err != nil {
+				t.Fatal(err)
+			}
+			data, err := json.MarshalIndent(in, "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for golden, got := range map[string][]byte{file + ".json": append(data, '\n'), file + ".txt": text.Bytes()} {
+				path := filepath.Join("testdata", golden)
+				if *update {
+					if err := os.WriteFile(path, got, 0o644); err != nil {
+						t.Fatal(err)
+					}
+					continue
+				}
+				want, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(got, want) {
+					t.Errorf("output differs from %s:\n%s", golden, got)
+				}
+			}
+		})
+	}
+}
+
+func TestInspectCertificateIssuer(t *testing.T) {
+	in, err := InspectFile("testdata/inspect.crl")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the entry without a certificate issuer extension belongs to the issuer of the entry before it
+	want := []struct {
+		serial string
+		issuer string
+	}{
+		{"0A", ""},
+		{"1234", "CN=Other CA,O=revokr"},
+		{"80", "CN=Other CA,O=revokr"},
+		{"0B", ""},
+	}
+	if len(in.Entries) != len(want) {
+		t.Fatalf("got %d entries, want %d", len(in.Entries), len(want))
+	}
+	for i, entry := range in.Entries {
+		var issuer string
+		if entry.CertificateIssuer != nil {
+			issuer = *entry.CertificateIssuer
+		}
+		if entry.Serial != want[i].serial || issuer != want[i].issuer {
+			t.Errorf("entry %d: got serial %s of issuer %q, want %s of %q", i, entry.Serial, issuer, want[i].serial, want[i].issuer)
+		}
+	}
+}
+
+func TestOpenSSLSerial(t *testing.T) {
+	tests := []struct {
+		serial *big.Int
+		want   string
+	}{
+		{big.NewInt(0), "00"},
+		{big.NewInt(10), "0A"},
+		{big.NewInt(0x80), "80"},
+		{big.NewInt(0x1234), "1234"},
+		{big.NewInt(0x12345), "012345"},
+		{big.NewInt(-1), "-01"},
+	}
+	for _, tt := range tests {
+		if got := opensslSerial(tt.serial); got != tt.want {
+			t.Errorf("opensslSerial(%s) = %s, want %s", tt.serial, got, tt.want)
+		}
+	}
+}
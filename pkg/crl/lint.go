@@ -0,0 +1,500 @@
+package crl
+
+import (
+	"bytes"
+	"encoding/asn1"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/goodieshq/revokr/pkg/util"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/crypto/cryptobyte"
+	cryptobyte_asn1 "golang.org/x/crypto/cryptobyte/asn1"
+)
+
+// LintSeverity is the severity of a lint finding. Errors are violations of RFC 5280 and fail the build of
+// a CRL, warnings and notices are only reported.
+type LintSeverity string
+
+const (
+	LintError   LintSeverity = "error"
+	LintWarning LintSeverity = "warning"
+	LintNotice  LintSeverity = "notice"
+)
+
+// lintChecks are the named checks run by Lint and their severity. The comments give the section of
+// RFC 5280 a check is based on.
+var lintChecks = map[string]LintSeverity{
+	"malformed":                       LintError,   // the CRL cannot be decoded as DER
+	"version":                         LintError,   // 5.1.2.1, v2 is required when extensions are present
+	"signature_algorithm_mismatch":    LintError,   // 5.1.1.2, outer and inner algorithms must be equal
+	"empty_issuer":                    LintError,   // 5.1.2.3
+	"time_encoding":                   LintError,   // 5.1.2.4, UTCTime through 2049, GeneralizedTime from 2050
+	"missing_next_update":             LintError,   // 5.1.2.5
+	"next_update_before_this_update":  LintError,   // 5.1.2.5
+	"empty_revoked_certificates":      LintError,   // 5.1.2.6, the list must be absent instead
+	"serial_not_positive":             LintError,   // 4.1.2.2
+	"serial_too_long":                 LintError,   // 4.1.2.2, at most 20 octets
+	"revocation_after_this_update":    LintWarning, // 5.1.2.6, a CRL cannot know of later revocations
+	"duplicate_entry":                 LintError,   // 5.1.2.6
+	"missing_authority_key_id":        LintError,   // 5.2.1
+	"missing_crl_number":              LintError,   // 5.2.3
+	"invalid_crl_number":              LintError,   // 5.2.3, non-negative and at most 20 octets
+	"delta_number_not_after_base":     LintError,   // 5.2.4
+	"freshest_crl_in_delta":           LintError,   // 5.2.6
+	"duplicate_extension":             LintError,   // 4.2
+	"extension_criticality":           LintError,   // 5.2 and 5.3
+	"unknown_critical_extension":      LintError,   // 5.2 and 5.3
+	"reason_code_unspecified":         LintNotice,  // 5.3.1, the reason code should be absent instead
+	"remove_from_crl_in_complete_crl": LintError,   // 5.3.1
+}
+
+// criticality of the known CRL and CRL entry extensions, true for critical
+var (
+	lintCRLExtensions = map[string]bool{
+		oidExtensionAuthorityKeyID.String():           false,
+		oidExtensionCRLNumber.String():                false,
+		oidExtensionDeltaCRLIndicator.String():        true,
+		oidExtensionIssuingDistributionPoint.String(): true,
+		oidExtensionFreshestCRL.String():              false,
+		"2.5.29.18":                                   false, // issuer alternative name
+		"2.5.29.60":                                   false, // expired certs on CRL
+		"1.3.6.1.5.5.7.1.1":                           false, // authority information access
+	}
+	lintEntryExtensions = map[string]bool{
+		oidExtensionReasonCode.String():        false,
+		oidExtensionInvalidityDate.String():    false,
+		oidExtensionCertificateIssuer.String(): true,
+		"2.5.29.23":                            false, // hold instruction code
+	}
+)
+
+// utcTimeLimit is the first time that must be encoded as GeneralizedTime rather than UTCTime.
+var utcTimeLimit = time.Date(2050, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// LintFinding is a violation of a lint check.
+type LintFinding struct {
+	Check    string       `json:"check"`
+	Severity LintSeverity `json:"severity"`
+	Message  string       `json:"message"`
+}
+
+// LintFile lints the CRL, TBS CRL or signing request at path.
+func LintFile(path string) ([]LintFinding, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	if IsSigningRequest(data) {
+		req, err := ReadSigningRequest(path)
+		if err != nil {
+			return nil, err
+		}
+		return Lint(req.TBS), nil
+	}
+
+	block, err := util.TryParsePEM(path)
+	if err != nil {
+		return nil, err
+	}
+	return Lint(block.Bytes), nil
+}
+
+// Lint runs the RFC 5280 checks against a DER encoded CRL or TBS CRL. The encoding is walked directly
+// rather than through crypto/x509, which hides the time encodings and rejects some malformed CRLs.
+func Lint(der []byte) []LintFinding {
+	l := &linter{}
+
+	// a signed CRL is a sequence of the TBS CRL, the signature algorithm and the signature
+	input := cryptobyte.String(der)
+	var outer, tbs, outerAlg cryptobyte.String
+	if input.ReadASN1(&outer, cryptobyte_asn1.SEQUENCE) && input.Empty() &&
+		outer.ReadASN1Element(&tbs, cryptobyte_asn1.SEQUENCE) &&
+		outer.ReadASN1Element(&outerAlg, cryptobyte_asn1.SEQUENCE) &&
+		outer.SkipASN1(cryptobyte_asn1.BIT_STRING) && outer.Empty() {
+		l.lintTBS(tbs, outerAlg)
+	} else {
+		l.lintTBS(der, nil)
+	}
+
+	return l.findings
+}
+
+// lintBeforeWrite lints a CRL before it is written. Warnings and notices are logged, errors fail the
+// build of the CRL.
+func lintBeforeWrite(der []byte) error {
+	var failed []string
+	for _, finding := range Lint(der) {
+		switch finding.Severity {
+		case LintError:
+			log.Error().Str("check", finding.Check).Msg(finding.Message)
+			failed = append(failed, finding.Check)
+		case LintWarning:
+			log.Warn().Str("check", finding.Check).Msg(finding.Message)
+		default:
+			log.Info().Str("check", finding.Check).Msg(finding.Message)
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("CRL fails %d lint checks: %s", len(failed), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+type linter struct {
+	findings []LintFinding
+}
+
+func (l *linter) add(check, format string, args ...any) {
+	l.findings = append(l.findings, LintFinding{
+		Check:    check,
+		Severity: lintChecks[check],
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// lintExtension is an extension as it is encoded, before its value is interpreted.
+type lintExtension struct {
+	oid      string
+	critical bool
+	value    []byte
+}
+
+func (l *linter) lintTBS(tbs []byte, outerAlg []byte) {
+	input := cryptobyte.String(tbs)
+	var seq cryptobyte.String
+	if !input.ReadASN1(&seq, cryptobyte_asn1.SEQUENCE) || !input.Empty() {
+		l.add("malformed", "not a DER encoded CRL or TBS CRL")
+		return
+	}
+
+	version := -1
+	if seq.PeekASN1Tag(cryptobyte_asn1.INTEGER) && !seq.ReadASN1Integer(&version) {
+		l.add("malformed", "malformed version")
+		return
+	}
+
+	var innerAlg cryptobyte.String
+	if !seq.ReadASN1Element(&innerAlg, cryptobyte_asn1.SEQUENCE) {
+		l.add("malformed", "malformed signature algorithm")
+		return
+	}
+	if outerAlg != nil && !bytes.Equal(innerAlg, outerAlg) {
+		l.add("signature_algorithm_mismatch", "signature algorithm of the CRL differs from the signature algorithm of the TBS CRL")
+	}
+
+	var issuer, rdns cryptobyte.String
+	if !seq.ReadASN1Element(&issuer, cryptobyte_asn1.SEQUENCE) {
+		l.add("malformed", "malformed issuer")
+		return
+	}
+	if element := issuer; !element.ReadASN1(&rdns, cryptobyte_asn1.SEQUENCE) || rdns.Empty() {
+		l.add("empty_issuer", "issuer name is empty")
+	}
+
+	thisUpdate, ok := l.readTime(&seq, "thisUpdate")
+	if !ok {
+		return
+	}
+	if seq.PeekASN1Tag(cryptobyte_asn1.UTCTime) || seq.PeekASN1Tag(cryptobyte_asn1.GeneralizedTime) {
+		nextUpdate, ok := l.readTime(&seq, "nextUpdate")
+		if !ok {
+			return
+		}
+		if !nextUpdate.After(thisUpdate) {
+			l.add("next_update_before_this_update", "nextUpdate %s is not after thisUpdate %s",
+				nextUpdate.Format(time.RFC3339), thisUpdate.Format(time.RFC3339))
+		}
+	} else {
+		l.add("missing_next_update", "nextUpdate is missing")
+	}
+
+	var hasEntryExtensions bool
+	var entryReasons []int
+	if seq.PeekASN1Tag(cryptobyte_asn1.SEQUENCE) {
+		var entries cryptobyte.String
+		if !seq.ReadASN1(&entries, cryptobyte_asn1.SEQUENCE) {
+			l.add("malformed", "malformed revoked certificates")
+			return
+		}
+		if entries.Empty() {
+			l.add("empty_revoked_certificates", "revoked certificates are present but empty, they must be omitted instead")
+		}
+		if hasEntryExtensions, entryReasons, ok = l.lintEntries(entries, issuer, thisUpdate); !ok {
+			return
+		}
+	}
+
+	var exts []lintExtension
+	if seq.PeekASN1Tag(cryptobyte_asn1.Tag(0).Constructed().ContextSpecific()) {
+		var explicit, extSeq cryptobyte.String
+		if !seq.ReadASN1(&explicit, cryptobyte_asn1.Tag(0).Constructed().ContextSpecific()) ||
+			!explicit.ReadASN1(&extSeq, cryptobyte_asn1.SEQUENCE) || !explicit.Empty() {
+			l.add("malformed", "malformed CRL extensions")
+			return
+		}
+		if exts, ok = l.readExtensions(extSeq, "CRL"); !ok {
+			return
+		}
+	}
+	if !seq.Empty() {
+		l.add("malformed", "trailing data after the CRL extensions")
+		return
+	}
+
+	switch {
+	case version != -1 && version != 1:
+		l.add("version", "version is %d, only v2 (1) is allowed when the version is present", version)
+	case version == -1 && (len(exts) > 0 || hasEntryExtensions):
+		l.add("version", "v1 CRL carries extensions, which requires v2")
+	}
+
+	l.lintCRLExtensions(exts, entryReasons)
+}
+
+// lintEntries lints the revoked certificates. It reports whether any entry carries extensions and
+// returns the reason codes used, for checks that depend on the CRL extensions.
+func (l *linter) lintEntries(entries cryptobyte.String, crlIssuer []byte, thisUpdate time.Time) (bool, []int, bool) {
+	var hasExtensions bool
+	var reasons []int
+	seen := make(map[string]struct{})
+	issuer := []byte(crlIssuer)
+
+	for n := 1; !entries.Empty(); n++ {
+		var entry, serial cryptobyte.String
+		if !entries.ReadASN1(&entry, cryptobyte_asn1.SEQUENCE) || !entry.ReadASN1(&serial, cryptobyte_asn1.INTEGER) {
+			l.add("malformed", "malformed revoked certificate entry %d", n)
+			return false, nil, false
+		}
+
+		// shown without the leading zero octet that keeps a positive serial number positive
+		serialHex := hex.EncodeToString(serial)
+		if len(serial) > 1 && serial[0] == 0 {
+			serialHex = hex.EncodeToString(serial[1:])
+		}
+		if len(serial) == 0 || len(serial) > 1 && (serial[0] == 0 && serial[1]&0x80 == 0 || serial[0] == 0xff && serial[1]&0x80 != 0) {
+			l.add("malformed", "serial number of entry %d is not minimally encoded", n)
+			return false, nil, false
+		}
+		if serial[0]&0x80 != 0 || new(big.Int).SetBytes(serial).Sign() == 0 {
+			l.add("serial_not_positive", "serial number %s of entry %d is not positive", serialHex, n)
+		}
+		if len(serial) > 20 {
+			l.add("serial_too_long", "serial number %s of entry %d is %d octets long, at most 20 are allowed", serialHex, n, len(serial))
+		}
+
+		revoked, ok := l.readTime(&entry, fmt.Sprintf("revocationDate of entry %d", n))
+		if !ok {
+			return false, nil, false
+		}
+		if revoked.After(thisUpdate) {
+			l.add("revocation_after_this_update", "revocationDate %s of serial number %s is after thisUpdate %s",
+				revoked.Format(time.RFC3339), serialHex, thisUpdate.Format(time.RFC3339))
+		}
+
+		if entry.PeekASN1Tag(cryptobyte_asn1.SEQUENCE) {
+			var extSeq cryptobyte.String
+			if !entry.ReadASN1(&extSeq, cryptobyte_asn1.SEQUENCE) {
+				l.add("malformed", "malformed extensions of entry %d", n)
+				return false, nil, false
+			}
+			exts, ok := l.readExtensions(extSeq, fmt.Sprintf("entry %d", n))
+			if !ok {
+				return false, nil, false
+			}
+			hasExtensions = hasExtensions || len(exts) > 0
+
+			for _, ext := range exts {
+				if critical, known := lintEntryExtensions[ext.oid]; known && critical != ext.critical {
+					l.add("extension_criticality", "%s extension of serial number %s must %sbe critical", extensionName(ext.oid), serialHex, notIf(critical))
+				} else if !known && ext.critical {
+					l.add("unknown_critical_extension", "serial number %s carries the unknown critical extension %s", serialHex, ext.oid)
+				}
+
+				switch ext.oid {
+				case oidExtensionReasonCode.String():
+					var reason asn1.Enumerated
+					if rest, err := asn1.Unmarshal(ext.value, &reason); err != nil || len(rest) > 0 {
+						l.add("malformed", "malformed reason code of serial number %s", serialHex)
+						continue
+					}
+					if reason == util.ReasonUnspecified {
+						l.add("reason_code_unspecified", "reason code of serial number %s is unspecified, the extension should be omitted instead", serialHex)
+					}
+					reasons = append(reasons, int(reason))
+				case oidExtensionInvalidityDate.String():
+					value := cryptobyte.String(ext.value)
+					var raw cryptobyte.String
+					if !value.ReadASN1(&raw, cryptobyte_asn1.GeneralizedTime) || !value.Empty() {
+						l.add("time_encoding", "invalidity date of serial number %s must be a GeneralizedTime", serialHex)
+					}
+				case oidExtensionCertificateIssuer.String():
+					name, err := parseCertificateIssuer(ext.value)
+					if err != nil {
+						l.add("malformed", "malformed certificate issuer of serial number %s", serialHex)
+						continue
+					}
+					issuer = name
+				}
+			}
+		}
+		if !entry.Empty() {
+			l.add("malformed", "trailing data in entry %d", n)
+			return false, nil, false
+		}
+
+		key := entryKey(issuer, serialHex)
+		if _, ok := seen[key]; ok {
+			l.add("duplicate_entry", "serial number %s is listed more than once for the same certificate issuer", serialHex)
+		}
+		seen[key] = struct{}{}
+	}
+
+	return hasExtensions, reasons, true
+}
+
+func (l *linter) lintCRLExtensions(exts []lintExtension, entryReasons []int) {
+	values := make(map[string][]byte)
+	for _, ext := range exts {
+		values[ext.oid] = ext.value
+		if critical, known := lintCRLExtensions[ext.oid]; known && critical != ext.critical {
+			l.add("extension_criticality", "%s extension must %sbe critical", extensionName(ext.oid), notIf(critical))
+		} else if !known && ext.critical {
+			l.add("unknown_critical_extension", "CRL carries the unknown critical extension %s", ext.oid)
+		}
+	}
+
+	if _, ok := values[oidExtensionAuthorityKeyID.String()]; !ok {
+		l.add("missing_authority_key_id", "authority key identifier extension is missing")
+	}
+
+	number, ok := values[oidExtensionCRLNumber.String()]
+	var crlNumber *big.Int
+	if !ok {
+		l.add("missing_crl_number", "CRL number extension is missing")
+	} else if crlNumber, ok = l.parseNumber(number, "CRL number"); !ok {
+		crlNumber = nil
+	}
+
+	base, delta := values[oidExtensionDeltaCRLIndicator.String()]
+	if delta {
+		if baseNumber, ok := l.parseNumber(base, "base CRL number"); ok && crlNumber != nil && crlNumber.Cmp(baseNumber) <= 0 {
+			l.add("delta_number_not_after_base", "delta CRL number %s is not greater than its base CRL number %s", crlNumber, baseNumber)
+		}
+		if _, ok := values[oidExtensionFreshestCRL.String()]; ok {
+			l.add("freshest_crl_in_delta", "freshest CRL extension must not appear in a delta CRL")
+		}
+	} else {
+		for _, reason := range entryReasons {
+			if reason == util.ReasonRemoveFromCRL {
+				l.add("remove_from_crl_in_complete_crl", "reason code removeFromCRL is only allowed in delta CRLs")
+				break
+			}
+		}
+	}
+}
+
+// parseNumber parses a CRL number or base CRL number, which must be non-negative and at most 20 octets.
+func (l *linter) parseNumber(value []byte, name string) (*big.Int, bool) {
+	var raw asn1.RawValue
+	number := new(big.Int)
+	if rest, err := asn1.Unmarshal(value, &raw); err != nil || len(rest) > 0 || raw.Tag != asn1.TagInteger {
+		l.add("invalid_crl_number", "%s is not a DER encoded integer", name)
+		return nil, false
+	}
+	if _, err := asn1.Unmarshal(value, &number); err != nil {
+		l.add("invalid_crl_number", "%s is not a DER encoded integer", name)
+		return nil, false
+	}
+	if number.Sign() < 0 || len(raw.Bytes) > 20 {
+		l.add("invalid_crl_number", "%s %s must be non-negative and at most 20 octets long", name, number)
+		return nil, false
+	}
+	return number, true
+}
+
+// readExtensions reads a sequence of extensions and reports duplicates.
+func (l *linter) readExtensions(input cryptobyte.String, owner string) ([]lintExtension, bool) {
+	var exts []lintExtension
+	seen := make(map[string]struct{})
+
+	for !input.Empty() {
+		var ext cryptobyte.String
+		var oid asn1.ObjectIdentifier
+		var critical bool
+		var value cryptobyte.String
+		// ReadOptionalASN1Boolean of this cryptobyte version cannot read a present BOOLEAN
+		if !input.ReadASN1(&ext, cryptobyte_asn1.SEQUENCE) || !ext.ReadASN1ObjectIdentifier(&oid) ||
+			ext.PeekASN1Tag(cryptobyte_asn1.BOOLEAN) && !ext.ReadASN1Boolean(&critical) ||
+			!ext.ReadASN1(&value, cryptobyte_asn1.OCTET_STRING) || !ext.Empty() {
+			l.add("malformed", "malformed extension of %s", owner)
+			return nil, false
+		}
+
+		if _, ok := seen[oid.String()]; ok {
+			l.add("duplicate_extension", "%s carries the %s extension more than once", owner, extensionName(oid.String()))
+		}
+		seen[oid.String()] = struct{}{}
+		exts = append(exts, lintExtension{oid: oid.String(), critical: critical, value: value})
+	}
+
+	return exts, true
+}
+
+// readTime reads a UTCTime or GeneralizedTime and checks that it uses the encoding RFC 5280 requires for
+// its date. Reports false if the time is malformed.
+func (l *linter) readTime(input *cryptobyte.String, field string) (time.Time, bool) {
+	var raw cryptobyte.String
+	var tag cryptobyte_asn1.Tag
+	if !input.ReadAnyASN1(&raw, &tag) {
+		l.add("malformed", "malformed %s", field)
+		return time.Time{}, false
+	}
+
+	s := string(raw)
+	switch tag {
+	case cryptobyte_asn1.UTCTime:
+		t, err := time.Parse("060102150405", strings.TrimSuffix(s, "Z"))
+		if len(s) != 13 || !strings.HasSuffix(s, "Z") || err != nil {
+			l.add("time_encoding", "%s %q must be a UTCTime of the form YYMMDDHHMMSSZ", field, s)
+			return t, err == nil
+		}
+		// two digit years from 50 are in the 20th century, Go only does so from 69
+		if t.Year() >= 2050 {
+			t = t.AddDate(-100, 0, 0)
+		}
+		return t, true
+	case cryptobyte_asn1.GeneralizedTime:
+		t, err := time.Parse("20060102150405", strings.TrimSuffix(s, "Z"))
+		if len(s) != 15 || !strings.HasSuffix(s, "Z") || err != nil {
+			l.add("time_encoding", "%s %q must be a GeneralizedTime of the form YYYYMMDDHHMMSSZ", field, s)
+			return t, err == nil
+		}
+		if t.Before(utcTimeLimit) {
+			l.add("time_encoding", "%s %s is before 2050 and must be encoded as UTCTime, not GeneralizedTime", field, t.Format(time.RFC3339))
+		}
+		return t, true
+	default:
+		l.add("malformed", "%s is neither a UTCTime nor a GeneralizedTime", field)
+		return time.Time{}, false
+	}
+}
+
+func extensionName(oid string) string {
+	if name, ok := extensionNames[oid]; ok {
+		return name
+	}
+	return oid
+}
+
+func notIf(critical bool) string {
+	if critical {
+		return ""
+	}
+	return "not "
+}
@@ -0,0 +1,334 @@
+package crl
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/goodieshq/revokr/pkg/util"
+	"golang.org/x/crypto/cryptobyte"
+	cryptobyte_asn1 "golang.org/x/crypto/cryptobyte/asn1"
+)
+
+// lintTime is a time as it is encoded, so tests can use either encoding for any date.
+type lintTime struct {
+	tag   cryptobyte_asn1.Tag
+	value string
+}
+
+func utcTime(s string) lintTime         { return lintTime{cryptobyte_asn1.UTCTime, s} }
+func generalizedTime(s string) lintTime { return lintTime{cryptobyte_asn1.GeneralizedTime, s} }
+
+type lintTestEntry struct {
+	serial  []byte // content octets of the INTEGER
+	revoked lintTime
+	exts    []pkix.Extension
+}
+
+// lintTestCRL describes a TBS CRL for buildTBS. Zero fields get valid defaults.
+type lintTestCRL struct {
+	thisUpdate lintTime
+	nextUpdate lintTime
+	entries    []lintTestEntry
+	exts       []pkix.Extension
+	noExts     bool // omit the default authority key identifier and CRL number
+}
+
+func buildTBS(t *testing.T, c lintTestCRL) []byte {
+	t.Helper()
+
+	if c.thisUpdate.value == "" {
+		c.thisUpdate = utcTime("240101000000Z")
+	}
+	if c.nextUpdate.value == "" {
+		c.nextUpdate = utcTime("240201000000Z")
+	}
+	exts := c.exts
+	if !c.noExts {
+		aki, _ := asn1.Marshal(struct {
+			ID []byte `asn1:"optional,tag:0"`
+		}{ID: []byte{1, 2, 3, 4}})
+		number, _ := asn1.Marshal(big.NewInt(1))
+		exts = append([]pkix.Extension{
+			{Id: oidExtensionAuthorityKeyID, Value: aki},
+			{Id: oidExtensionCRLNumber, Value: number},
+		}, exts...)
+	}
+
+	addTime := func(b *cryptobyte.Builder, lt lintTime) {
+		b.AddASN1(lt.tag, func(b *cryptobyte.Builder) { b.AddBytes([]byte(lt.value)) })
+	}
+	addExtensions := func(b *cryptobyte.Builder, exts []pkix.Extension) {
+		b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+			for _, ext := range exts {
+				b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+					b.AddASN1ObjectIdentifier(ext.Id)
+					if ext.Critical {
+						b.AddASN1Boolean(true)
+					}
+					b.AddASN1OctetString(ext.Value)
+				})
+			}
+		})
+	}
+
+	alg, _ := asn1.Marshal(pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}})
+
+	var b cryptobyte.Builder
+	b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+		b.AddASN1Int64(1)
+		b.AddBytes(alg)
+		b.AddBytes(rawName(t, "Lint Test CA"))
+		addTime(b, c.thisUpdate)
+		addTime(b, c.nextUpdate)
+		if len(c.entries) > 0 {
+			b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+				for _, entry := range c.entries {
+					b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+						b.AddASN1(cryptobyte_asn1.INTEGER, func(b *cryptobyte.Builder) { b.AddBytes(entry.serial) })
+						revoked := entry.revoked
+						if revoked.value == "" {
+							revoked = utcTime("231201000000Z")
+						}
+						addTime(b, revoked)
+						if len(entry.exts) > 0 {
+							addExtensions(b, entry.exts)
+						}
+					})
+				}
+			})
+		}
+		if len(exts) > 0 {
+			b.AddASN1(cryptobyte_asn1.Tag(0).Constructed().ContextSpecific(), func(b *cryptobyte.Builder) {
+				addExtensions(b, exts)
+			})
+		}
+	})
+
+	der, err := b.Bytes()
+	if err != nil {
+		t.Fatalf("failed to build TBS CRL: %v", err)
+	}
+	return der
+}
+
+func lintChecksOf(findings []LintFinding) []string {
+	var checks []string
+	for _, finding := range findings {
+		checks = append(checks, finding.Check)
+	}
+	return checks
+}
+
+func TestLint(t *testing.T) {
+	otherIssuer := certificateIssuerExtension(rawName(t, "Other CA"))
+	unknownExtension := pkix.Extension{Id: asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 99999, 1}, Critical: true, Value: []byte{5, 0}}
+
+	tests := []struct {
+		name string
+		crl  lintTestCRL
+		want []string // checks expected to fail, in order
+	}{
+		{
+			name: "clean",
+			crl: lintTestCRL{entries: []lintTestEntry{
+				{serial: []byte{0x01}},
+				{serial: []byte{0x00, 0x80}}, // leading zero octet keeps the serial positive
+				{serial: append([]byte{0x7f}, make([]byte, 19)...)},
+			}},
+		},
+		{
+			name: "serial zero",
+			crl:  lintTestCRL{entries: []lintTestEntry{{serial: []byte{0x00}}}},
+			want: []string{"serial_not_positive"},
+		},
+		{
+			name: "serial negative",
+			crl:  lintTestCRL{entries: []lintTestEntry{{serial: []byte{0xfe, 0x01}}}},
+			want: []string{"serial_not_positive"},
+		},
+		{
+			name: "serial of 21 octets",
+			crl:  lintTestCRL{entries: []lintTestEntry{{serial: append([]byte{0x01}, make([]byte, 20)...)}}},
+			want: []string{"serial_too_long"},
+		},
+		{
+			name: "serial not minimally encoded",
+			crl:  lintTestCRL{entries: []lintTestEntry{{serial: []byte{0x00, 0x01}}}},
+			want: []string{"malformed"},
+		},
+		{
+			name: "UTCTime until 2049",
+			crl:  lintTestCRL{thisUpdate: utcTime("491231000000Z"), nextUpdate: utcTime("491231235959Z")},
+		},
+		{
+			name: "GeneralizedTime from 2050",
+			crl:  lintTestCRL{thisUpdate: utcTime("491231235959Z"), nextUpdate: generalizedTime("20500101000000Z")},
+		},
+		{
+			name: "GeneralizedTime before 2050",
+			crl:  lintTestCRL{thisUpdate: generalizedTime("20240101000000Z"), nextUpdate: generalizedTime("20491231235959Z")},
+			want: []string{"time_encoding", "time_encoding"},
+		},
+		{
+			name: "UTCTime 50 is 1950",
+			crl:  lintTestCRL{nextUpdate: utcTime("500101000000Z")},
+			want: []string{"next_update_before_this_update"},
+		},
+		{
+			name: "UTCTime without seconds",
+			crl:  lintTestCRL{thisUpdate: utcTime("2401010000Z")},
+			want: []string{"time_encoding"},
+		},
+		{
+			name: "revocation date as GeneralizedTime before 2050",
+			crl:  lintTestCRL{entries: []lintTestEntry{{serial: []byte{0x01}, revoked: generalizedTime("20231201000000Z")}}},
+			want: []string{"time_encoding"},
+		},
+		{
+			name: "duplicate serial",
+			crl:  lintTestCRL{entries: []lintTestEntry{{serial: []byte{0x01}}, {serial: []byte{0x01}}}},
+			want: []string{"duplicate_entry"},
+		},
+		{
+			name: "same serial of another certificate issuer",
+			crl: lintTestCRL{entries: []lintTestEntry{
+				{serial: []byte{0x01}},
+				{serial: []byte{0x01}, exts: []pkix.Extension{otherIssuer}},
+			}},
+		},
+		{
+			// the certificate issuer is carried forward to the third entry, which repeats the second
+			name: "duplicate serial with carried forward certificate issuer",
+			crl: lintTestCRL{entries: []lintTestEntry{
+				{serial: []byte{0x01}},
+				{serial: []byte{0x02}, exts: []pkix.Extension{otherIssuer}},
+				{serial: []byte{0x01}},
+				{serial: []byte{0x02}},
+			}},
+			want: []string{"duplicate_entry"},
+		},
+		{
+			name: "unknown critical CRL extension",
+			crl:  lintTestCRL{exts: []pkix.Extension{unknownExtension}},
+			want: []string{"unknown_critical_extension"},
+		},
+		{
+			name: "unknown critical entry extension",
+			crl:  lintTestCRL{entries: []lintTestEntry{{serial: []byte{0x01}, exts: []pkix.Extension{unknownExtension}}}},
+			want: []string{"unknown_critical_extension"},
+		},
+		{
+			name: "unknown non-critical extension",
+			crl:  lintTestCRL{exts: []pkix.Extension{{Id: unknownExtension.Id, Value: unknownExtension.Value}}},
+		},
+		{
+			name: "missing extensions",
+			crl:  lintTestCRL{noExts: true},
+			want: []string{"missing_authority_key_id", "missing_crl_number"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := lintChecksOf(Lint(buildTBS(t, tt.crl)))
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("failed checks = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLintCreateCRL(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Lint Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crt, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := &x509.Certificate{RawSubject: rawName(t, "Other CA")}
+
+	dir := t.TempDir()
+	revocations := []util.RevocationRecord{
+		{Serial: "1", Reason: util.ReasonKeyCompromise, InvalidityDate: time.Now().Add(-2 * time.Hour)},
+		{Serial: "2", Reason: util.ReasonCertificateHold},
+		{Serial: "1", Reason: util.ReasonSuperseded, Issuer: other},
+	}
+
+	tests := []struct {
+		name   string
+		params CreateCRLParams
+	}{
+		{
+			name: "complete",
+			params: CreateCRLParams{
+				Revocations:    revocations,
+				SerialsInclude: []string{"abcdef"},
+				CRLNumber:      big.NewInt(1),
+				FreshestCRL:    []string{"http://pki.example.com/delta.crl"},
+			},
+		},
+		{
+			name: "delta",
+			params: CreateCRLParams{
+				Revocations:   []util.RevocationRecord{{Serial: "2", Reason: util.ReasonRemoveFromCRL}},
+				CRLNumber:     big.NewInt(2),
+				BaseCRLNumber: big.NewInt(1),
+			},
+		},
+		{
+			name: "tbs",
+			params: CreateCRLParams{
+				Revocations: revocations,
+				CRLNumber:   big.NewInt(3),
+				TBS:         true,
+				DigestPath:  filepath.Join(dir, "tbs.digest"),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := tt.params
+			params.OutPath = filepath.Join(dir, tt.name+".crl")
+			signer := key
+			if params.TBS {
+				signer = nil
+			}
+			if err := CreateCRL(crt, signer, &params); err != nil {
+				t.Fatalf("CreateCRL failed: %v", err)
+			}
+
+			findings, err := LintFile(params.OutPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(findings) > 0 {
+				t.Errorf("lint findings on a CRL written by CreateCRL: %v", findings)
+			}
+		})
+	}
+}
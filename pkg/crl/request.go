@@ -0,0 +1,214 @@
+package crl
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goodieshq/revokr/pkg/util"
+	"github.com/rs/zerolog/log"
+)
+
+const signingRequestPEMType = "REVOKR SIGNING REQUEST"
+
+// Headers of the signing request PEM block.
+const (
+	headerIssuerFingerprint  = "Issuer-Fingerprint"
+	headerSignatureAlgorithm = "Signature-Algorithm"
+	headerHash               = "Hash"
+	headerPadding            = "Padding"
+	headerCRLNumber          = "CRL-Number"
+	headerBaseCRLNumber      = "Base-CRL-Number"
+	headerThisUpdate         = "This-Update"
+	headerNextUpdate         = "Next-Update"
+	headerEntries            = "Entries"
+	headerDigest             = "Digest"
+)
+
+// SigningRequest is a self-describing envelope around a TBS CRL for air-gapped signing. It is stored
+// as a PEM block whose headers describe the TBS CRL, followed by the issuer certificate.
+type SigningRequest struct {
+	TBS                []byte
+	Issuer             *x509.Certificate
+	SignatureAlgorithm x509.SignatureAlgorithm
+	CRLNumber          *big.Int
+	BaseCRLNumber      *big.Int // nil unless the TBS CRL is a delta CRL
+	ThisUpdate         time.Time
+	NextUpdate         time.Time
+	Entries            int
+	Digest             string // "<hash>:<hex>", identifies the request
+}
+
+// NewSigningRequest describes a TBS CRL issued by crt.
+func NewSigningRequest(crt *x509.Certificate, tbs []byte) (*SigningRequest, error) {
+	rl, err := util.ParseTBS(tbs)
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.Equal(rl.RawIssuer, crt.RawSubject) {
+		return nil, fmt.Errorf("TBS CRL issuer %q does not match the issuer certificate %q", rl.Issuer, crt.Subject)
+	}
+
+	req := &SigningRequest{
+		TBS:                tbs,
+		Issuer:             crt,
+		SignatureAlgorithm: rl.SignatureAlgorithm,
+		CRLNumber:          rl.Number,
+		ThisUpdate:         rl.ThisUpdate,
+		NextUpdate:         rl.NextUpdate,
+		Entries:            len(rl.RevokedCertificateEntries),
+	}
+
+	if req.BaseCRLNumber, err = DeltaBaseNumber(rl); err != nil {
+		return nil, err
+	}
+
+	if req.Digest, err = signingRequestDigest(tbs, rl.SignatureAlgorithm); err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+// signingRequestDigest hashes the TBS CRL with the hash of the signature algorithm, or SHA-256 for
+// Ed25519 which does not prehash.
+func signingRequestDigest(tbs []byte, alg x509.SignatureAlgorithm) (string, error) {
+	_, h, err := util.GetSignatureAlgAndHash(alg)
+	if err != nil {
+		return "", err
+	}
+	if h == 0 {
+		h = crypto.SHA256
+	}
+
+	hasher := h.New()
+	hasher.Write(tbs)
+	return h.String() + ":" + hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// headers returns the PEM headers describing the signing request.
+func (r *SigningRequest) headers() map[string]string {
+	_, h, _ := util.GetSignatureAlgAndHash(r.SignatureAlgorithm)
+	hashName := "none"
+	if h != 0 {
+		hashName = h.String()
+	}
+
+	headers := map[string]string{
+		headerIssuerFingerprint:  util.Fingerprint(r.Issuer),
+		headerSignatureAlgorithm: r.SignatureAlgorithm.String(),
+		headerHash:               hashName,
+		headerPadding:            util.SignaturePadding(r.SignatureAlgorithm),
+		headerCRLNumber:          r.CRLNumber.String(),
+		headerThisUpdate:         r.ThisUpdate.UTC().Format(time.RFC3339),
+		headerNextUpdate:         r.NextUpdate.UTC().Format(time.RFC3339),
+		headerEntries:            strconv.Itoa(r.Entries),
+		headerDigest:             r.Digest,
+	}
+	if r.BaseCRLNumber != nil {
+		headers[headerBaseCRLNumber] = r.BaseCRLNumber.String()
+	}
+	return headers
+}
+
+// Encode returns the PEM encoding of the signing request including the issuer certificate.
+func (r *SigningRequest) Encode() []byte {
+	var buf bytes.Buffer
+	pem.Encode(&buf, &pem.Block{Type: signingRequestPEMType, Headers: r.headers(), Bytes: r.TBS})
+	pem.Encode(&buf, &pem.Block{Type: "CERTIFICATE", Bytes: r.Issuer.Raw})
+	return buf.Bytes()
+}
+
+// WriteSigningRequest writes the signing request to the given path.
+func WriteSigningRequest(path string, r *SigningRequest) error {
+	if err := os.WriteFile(path, r.Encode(), 0644); err != nil {
+		return fmt.Errorf("failed to write signing request: %w", err)
+	}
+	return nil
+}
+
+// IsSigningRequest reports whether data contains a signing request envelope.
+func IsSigningRequest(data []byte) bool {
+	return bytes.Contains(data, []byte("-----BEGIN "+signingRequestPEMType+"-----"))
+}
+
+// ReadSigningRequest reads a signing request envelope and checks that its headers and issuer
+// certificate are consistent with the TBS CRL it carries.
+func ReadSigningRequest(path string) (*SigningRequest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing request: %w", err)
+	}
+
+	var reqBlock *pem.Block
+	var crt *x509.Certificate
+	for rest := data; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		switch block.Type {
+		case signingRequestPEMType:
+			reqBlock = block
+		case "CERTIFICATE":
+			if crt, err = x509.ParseCertificate(block.Bytes); err != nil {
+				return nil, fmt.Errorf("failed to parse issuer certificate of signing request: %w", err)
+			}
+		}
+	}
+
+	if reqBlock == nil {
+		return nil, fmt.Errorf("no %s block found", signingRequestPEMType)
+	}
+	if crt == nil {
+		return nil, fmt.Errorf("signing request does not contain the issuer certificate")
+	}
+
+	if fp := util.Fingerprint(crt); reqBlock.Headers[headerIssuerFingerprint] != fp {
+		return nil, fmt.Errorf("issuer fingerprint %s does not match the included issuer certificate %s", reqBlock.Headers[headerIssuerFingerprint], fp)
+	}
+
+	req, err := NewSigningRequest(crt, reqBlock.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TBS CRL in signing request: %w", err)
+	}
+
+	// every header must describe the TBS CRL it is attached to
+	for name, value := range req.headers() {
+		if got := reqBlock.Headers[name]; got != value {
+			return nil, fmt.Errorf("signing request header %s is %q but the TBS CRL has %q", name, got, value)
+		}
+	}
+
+	log.Debug().Msgf("Read signing request %s from file %q", req.Digest, path)
+	return req, nil
+}
+
+// CheckIssuer verifies that the signing request was created for the given issuer certificate.
+func (r *SigningRequest) CheckIssuer(crt *x509.Certificate) error {
+	if !bytes.Equal(r.Issuer.Raw, crt.Raw) {
+		return fmt.Errorf("signing request was created for issuer %s, not %s", util.Fingerprint(r.Issuer), util.Fingerprint(crt))
+	}
+	return nil
+}
+
+// String summarizes the signing request on a single line.
+func (r *SigningRequest) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "CRL %s", r.CRLNumber)
+	if r.BaseCRLNumber != nil {
+		fmt.Fprintf(&sb, " (delta of %s)", r.BaseCRLNumber)
+	}
+	fmt.Fprintf(&sb, " with %d entries, digest %s", r.Entries, r.Digest)
+	return sb.String()
+}
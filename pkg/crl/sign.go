@@ -0,0 +1,94 @@
+package crl
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+
+	"github.com/goodieshq/revokr/pkg/util"
+)
+
+const signaturePEMType = "REVOKR SIGNATURE"
+
+// headerRequestDigest ties a signature to the signing request it was made for.
+const headerRequestDigest = "Request-Digest"
+
+// SignTBS signs a TBS CRL with the issuer key using the signature algorithm of the TBS CRL. The result
+// is the signature in the form expected by AssembleCRL.
+func SignTBS(tbs []byte, key crypto.Signer) ([]byte, error) {
+	rl, err := util.ParseTBS(tbs)
+	if err != nil {
+		return nil, err
+	}
+
+	alg := rl.SignatureAlgorithm
+	if err := util.CheckSignatureAlgorithm(alg, key.Public()); err != nil {
+		return nil, fmt.Errorf("invalid signature algorithm: %w", err)
+	}
+
+	_, h, err := util.GetSignatureAlgAndHash(alg)
+	if err != nil {
+		return nil, err
+	}
+
+	// Ed25519 signs the message itself
+	signed := tbs
+	var opts crypto.SignerOpts = h
+	if h != 0 {
+		hasher := h.New()
+		hasher.Write(tbs)
+		signed = hasher.Sum(nil)
+	}
+	switch alg {
+	case x509.SHA256WithRSAPSS, x509.SHA384WithRSAPSS, x509.SHA512WithRSAPSS:
+		opts = &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: h}
+	}
+
+	signature, err := key.Sign(rand.Reader, signed, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign TBS CRL: %w", err)
+	}
+
+	return signature, nil
+}
+
+// WriteSignature writes a signature for AssembleCRL. Signatures of a signing request are written as
+// PEM carrying the digest of the request, other signatures are written as binary.
+func WriteSignature(path string, signature []byte, req *SigningRequest) error {
+	data := signature
+	if req != nil {
+		data = pem.EncodeToMemory(&pem.Block{
+			Type:    signaturePEMType,
+			Headers: map[string]string{headerRequestDigest: req.Digest},
+			Bytes:   signature,
+		})
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write signature: %w", err)
+	}
+	return nil
+}
+
+// CheckSignatureFile rejects a signature file that was written by WriteSignature for another signing
+// request. Signature files without a request digest are accepted.
+func (r *SigningRequest) CheckSignatureFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read signature file: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != signaturePEMType {
+		return nil
+	}
+
+	if digest, ok := block.Headers[headerRequestDigest]; ok && digest != r.Digest {
+		return fmt.Errorf("signature was made for signing request %s, not %s", digest, r.Digest)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package crl
+
+import (
+	"bytes"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Errors returned by VerifyCRL, so callers can tell a broken CRL from one that is merely outside its
+// validity window.
+var (
+	ErrCRLInvalid     = errors.New("CRL is invalid")
+	ErrCRLExpired     = errors.New("CRL has expired")
+	ErrCRLNotYetValid = errors.New("CRL is not yet valid")
+)
+
+// VerifyCRL checks a DER encoded CRL against the candidate issuer certificates, which may be a single
+// certificate or a whole chain. The issuer is the certificate whose subject matches the CRL issuer, whose
+// subject key identifier matches the authority key identifier of the CRL and whose key verifies the
+// signature. It must be allowed to sign CRLs, and the CRL must be valid at the given time. The parsed CRL
+// and the issuer certificate are returned as far as they could be determined.
+func VerifyCRL(der []byte, crts []*x509.Certificate, at time.Time) (*x509.RevocationList, *x509.Certificate, error) {
+	rl, err := x509.ParseRevocationList(der)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: failed to parse CRL: %v", ErrCRLInvalid, err)
+	}
+
+	var named []*x509.Certificate
+	for _, crt := range crts {
+		if bytes.Equal(crt.RawSubject, rl.RawIssuer) {
+			named = append(named, crt)
+		}
+	}
+	if len(named) == 0 {
+		return rl, nil, fmt.Errorf("%w: no issuer certificate with the subject %q of the CRL issuer", ErrCRLInvalid, rl.Issuer)
+	}
+
+	// certificates without a subject key identifier cannot be told apart by the authority key identifier
+	candidates := named
+	if len(rl.AuthorityKeyId) > 0 {
+		candidates = nil
+		for _, crt := range named {
+			if len(crt.SubjectKeyId) == 0 || bytes.Equal(crt.SubjectKeyId, rl.AuthorityKeyId) {
+				candidates = append(candidates, crt)
+			}
+		}
+		if len(candidates) == 0 {
+			return rl, nil, fmt.Errorf("%w: CRL authority key identifier %X does not match the subject key identifier %X of the issuer certificate",
+				ErrCRLInvalid, rl.AuthorityKeyId, named[0].SubjectKeyId)
+		}
+	}
+
+	// an issuer certificate without a key usage extension may sign CRLs. Check this before the signature,
+	// which is rejected with a less helpful error for such certificates.
+	var signers []*x509.Certificate
+	for _, crt := range candidates {
+		if crt.KeyUsage == 0 || crt.KeyUsage&x509.KeyUsageCRLSign != 0 {
+			signers = append(signers, crt)
+		}
+	}
+	if len(signers) == 0 {
+		return rl, candidates[0], fmt.Errorf("%w: issuer certificate key usage does not include cRLSign", ErrCRLInvalid)
+	}
+
+	var issuer *x509.Certificate
+	for _, crt := range signers {
+		if err = rl.CheckSignatureFrom(crt); err == nil {
+			issuer = crt
+			break
+		}
+	}
+	if issuer == nil {
+		return rl, signers[0], fmt.Errorf("%w: signature verification failed: %v", ErrCRLInvalid, err)
+	}
+
+	return rl, issuer, checkValidity(rl, at)
+}
+
+// checkValidity returns ErrCRLNotYetValid or ErrCRLExpired if the CRL is not valid at the given time.
+func checkValidity(rl *x509.RevocationList, at time.Time) error {
+	if at.Before(rl.ThisUpdate) {
+		return fmt.Errorf("%w: thisUpdate %s is after %s", ErrCRLNotYetValid,
+			rl.ThisUpdate.UTC().Format(time.RFC3339), at.UTC().Format(time.RFC3339))
+	}
+	if !rl.NextUpdate.IsZero() && !at.Before(rl.NextUpdate) {
+		return fmt.Errorf("%w: nextUpdate %s is not after %s", ErrCRLExpired,
+			rl.NextUpdate.UTC().Format(time.RFC3339), at.UTC().Format(time.RFC3339))
+	}
+	return nil
+}
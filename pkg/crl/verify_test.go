@@ -0,0 +1,145 @@
+package crl
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCA is a CA certificate and its key for the VerifyCRL tests.
+type testCA struct {
+	crt *x509.Certificate
+	key crypto.Signer
+}
+
+// newTestCA issues a CA certificate for key, self-signed or signed by parent.
+func newTestCA(t *testing.T, cn string, key crypto.Signer, ski []byte, usage x509.KeyUsage, parent *testCA) *testCA {
+	t.Helper()
+	if key == nil {
+		var err error
+		if key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+			t.Fatal(err)
+		}
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              usage,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		SubjectKeyId:          ski,
+	}
+	issuer, signer := template, key
+	if parent != nil {
+		issuer, signer = parent.crt, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, issuer, key.Public(), signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crt, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCA{crt: crt, key: key}
+}
+
+func (ca *testCA) crl(t *testing.T, thisUpdate, nextUpdate time.Time) []byte {
+	t.Helper()
+	der, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:     big.NewInt(1),
+		ThisUpdate: thisUpdate,
+		NextUpdate: nextUpdate,
+	}, ca.crt, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+func TestVerifyCRL(t *testing.T) {
+	usage := x509.KeyUsageCertSign | x509.KeyUsageCRLSign
+	root := newTestCA(t, "Root CA", nil, []byte{1}, usage, nil)
+	ca := newTestCA(t, "Issuing CA", nil, []byte{2}, usage, root)
+	other := newTestCA(t, "Other CA", nil, []byte{3}, usage, root)
+
+	// certificates with the name of the issuing CA that did not sign its CRL
+	otherSKI := newTestCA(t, "Issuing CA", ca.key, []byte{4}, usage, root)
+	noCRLSign := newTestCA(t, "Issuing CA", ca.key, []byte{2}, x509.KeyUsageCertSign, root)
+	noKeyUsage := newTestCA(t, "Issuing CA", ca.key, []byte{2}, 0, root)
+	otherKey := newTestCA(t, "Issuing CA", nil, []byte{2}, usage, root)
+	rekeyed := newTestCA(t, "Issuing CA", nil, []byte{5}, usage, root)
+
+	now := time.Now()
+	valid := ca.crl(t, now.Add(-time.Hour), now.Add(time.Hour))
+
+	tests := []struct {
+		name    string
+		der     []byte
+		crts    []*x509.Certificate
+		at      time.Time
+		want    error             // nil for a valid CRL
+		errText string            // contained in the error
+		issuer  *x509.Certificate // issuer returned, nil for none
+	}{
+		{"valid", valid, []*x509.Certificate{ca.crt}, now, nil, "", ca.crt},
+		{"issuer in a chain", valid, []*x509.Certificate{root.crt, other.crt, ca.crt}, now, nil, "", ca.crt},
+		{"re-keyed issuer in a chain", valid, []*x509.Certificate{rekeyed.crt, ca.crt}, now, nil, "", ca.crt},
+		{"issuer with the same key identifier in a chain", valid, []*x509.Certificate{otherKey.crt, ca.crt}, now, nil, "", ca.crt},
+		{"issuer without key usage", valid, []*x509.Certificate{noKeyUsage.crt}, now, nil, "", noKeyUsage.crt},
+		{"wrong issuer name", valid, []*x509.Certificate{root.crt, other.crt}, now, ErrCRLInvalid, "no issuer certificate with the subject", nil},
+		{"authority key identifier mismatch", valid, []*x509.Certificate{otherSKI.crt}, now, ErrCRLInvalid, "authority key identifier 02 does not match the subject key identifier 04", nil},
+		{"missing cRLSign", valid, []*x509.Certificate{noCRLSign.crt}, now, ErrCRLInvalid, "cRLSign", noCRLSign.crt},
+		{"bad signature", valid, []*x509.Certificate{otherKey.crt}, now, ErrCRLInvalid, "signature verification failed", otherKey.crt},
+		{"not a CRL", ca.crt.Raw, []*x509.Certificate{ca.crt}, now, ErrCRLInvalid, "failed to parse CRL", nil},
+		{"expired", valid, []*x509.Certificate{ca.crt}, now.Add(2 * time.Hour), ErrCRLExpired, "nextUpdate", ca.crt},
+		{"expired at nextUpdate", ca.crl(t, now.Add(-time.Hour), now), []*x509.Certificate{ca.crt}, now, ErrCRLExpired, "nextUpdate", ca.crt},
+		{"not yet valid", valid, []*x509.Certificate{ca.crt}, now.Add(-2 * time.Hour), ErrCRLNotYetValid, "thisUpdate", ca.crt},
+		{"not yet valid in a chain", valid, []*x509.Certificate{root.crt, ca.crt}, now.Add(-2 * time.Hour), ErrCRLNotYetValid, "thisUpdate", ca.crt},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rl, issuer, err := VerifyCRL(tt.der, tt.crts, tt.at)
+
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			} else {
+				if !errors.Is(err, tt.want) {
+					t.Fatalf("got error %v, want %v", err, tt.want)
+				}
+				// exactly one class of error, the exit code of verify depends on it
+				for _, class := range []error{ErrCRLInvalid, ErrCRLExpired, ErrCRLNotYetValid} {
+					if class != tt.want && errors.Is(err, class) {
+						t.Errorf("error %v is also %v", err, class)
+					}
+				}
+				if !strings.Contains(err.Error(), tt.errText) {
+					t.Errorf("got error %v, want one containing %q", err, tt.errText)
+				}
+			}
+
+			if (rl == nil) != (tt.name == "not a CRL") {
+				t.Errorf("got CRL %v", rl)
+			}
+			switch {
+			case tt.issuer == nil && issuer != nil:
+				t.Errorf("got issuer %s, want none", issuer.Subject)
+			case tt.issuer != nil && (issuer == nil || !issuer.Equal(tt.issuer)):
+				t.Errorf("got issuer %v, want %s with SKI %X", issuer, tt.issuer.Subject, tt.issuer.SubjectKeyId)
+			}
+		})
+	}
+}
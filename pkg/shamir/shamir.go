@@ -0,0 +1,207 @@
+// Package shamir implements Shamir's secret sharing over GF(2^8), used to split a key passphrase among
+// custodians so that any M of the N shares reconstruct it.
+package shamir
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// sharePrefix starts the text form of every share, see Share.String.
+const sharePrefix = "revokr-share-v1"
+
+// Share is one share of a split secret.
+type Share struct {
+	SetID     [4]byte // random identifier shared by all shares of one split
+	Threshold int     // number of shares needed to reconstruct the secret
+	Index     int     // x coordinate of the share, 1 to 255
+	Value     []byte  // one polynomial evaluation per secret byte
+}
+
+// Split splits secret into n shares of which any threshold reconstruct it.
+func Split(secret []byte, n, threshold int) ([]Share, error) {
+	if len(secret) == 0 {
+		return nil, fmt.Errorf("secret is empty")
+	}
+	if threshold < 2 || threshold > n || n > 255 {
+		return nil, fmt.Errorf("invalid %d-of-%d split, need 2 <= threshold <= shares <= 255", threshold, n)
+	}
+
+	var setID [4]byte
+	if _, err := rand.Read(setID[:]); err != nil {
+		return nil, err
+	}
+
+	shares := make([]Share, n)
+	for i := range shares {
+		shares[i] = Share{SetID: setID, Threshold: threshold, Index: i + 1, Value: make([]byte, len(secret))}
+	}
+
+	// one random polynomial of degree threshold-1 per secret byte, with the byte as constant term
+	coeffs := make([]byte, threshold)
+	defer clear(coeffs)
+	for b, s := range secret {
+		coeffs[0] = s
+		if _, err := rand.Read(coeffs[1:]); err != nil {
+			return nil, err
+		}
+		for i := range shares {
+			shares[i].Value[b] = evaluate(coeffs, byte(shares[i].Index))
+		}
+	}
+
+	return shares, nil
+}
+
+// Combine reconstructs the secret from at least Threshold shares of the same split.
+func Combine(shares []Share) ([]byte, error) {
+	if len(shares) == 0 {
+		return nil, fmt.Errorf("no shares given")
+	}
+	for i := range shares[1:] {
+		if err := Compatible(shares[:i+1], shares[i+1]); err != nil {
+			return nil, err
+		}
+	}
+	if len(shares) < shares[0].Threshold {
+		return nil, fmt.Errorf("%d shares given, %d are needed", len(shares), shares[0].Threshold)
+	}
+	shares = shares[:shares[0].Threshold]
+
+	// Lagrange interpolation at x = 0
+	secret := make([]byte, len(shares[0].Value))
+	for i, si := range shares {
+		xi := byte(si.Index)
+		basis := byte(1)
+		for j, sj := range shares {
+			if i == j {
+				continue
+			}
+			xj := byte(sj.Index)
+			basis = mul(basis, div(xj, xj^xi))
+		}
+		for b := range secret {
+			secret[b] ^= mul(basis, si.Value[b])
+		}
+	}
+
+	return secret, nil
+}
+
+// Compatible reports whether share can be combined with shares: it must belong to the same split and
+// have an index not used yet.
+func Compatible(shares []Share, share Share) error {
+	for _, s := range shares {
+		if s.SetID != share.SetID || s.Threshold != share.Threshold || len(s.Value) != len(share.Value) {
+			return fmt.Errorf("share %d belongs to a different split than share %d", share.Index, s.Index)
+		}
+		if s.Index == share.Index {
+			return fmt.Errorf("share %d was given twice", share.Index)
+		}
+	}
+	return nil
+}
+
+// String encodes the share as
+//
+//	revokr-share-v1-<set id>-<threshold>-<index>-<value>-<checksum>
+//
+// where the set id and value are hex and the checksum is the first 4 bytes of the SHA-256 of everything
+// before it, so typing errors are caught before the secret is reconstructed.
+func (s Share) String() string {
+	body := fmt.Sprintf("%s-%x-%d-%d-%x", sharePrefix, s.SetID, s.Threshold, s.Index, s.Value)
+	return body + "-" + shareChecksum(body)
+}
+
+// ParseShare decodes a share in the form written by Share.String. Whitespace and case are ignored.
+func ParseShare(text string) (Share, error) {
+	text = strings.ToLower(strings.Join(strings.Fields(text), ""))
+
+	body, checksum, ok := cutLast(text, "-")
+	if !ok || !strings.HasPrefix(body, sharePrefix+"-") {
+		return Share{}, fmt.Errorf("not a revokr share")
+	}
+	if checksum != shareChecksum(body) {
+		return Share{}, fmt.Errorf("share checksum mismatch, check for typing errors")
+	}
+
+	parts := strings.Split(strings.TrimPrefix(body, sharePrefix+"-"), "-")
+	if len(parts) != 4 {
+		return Share{}, fmt.Errorf("malformed share")
+	}
+
+	var s Share
+	setID, err := hex.DecodeString(parts[0])
+	if err != nil || len(setID) != len(s.SetID) {
+		return Share{}, fmt.Errorf("malformed share set id")
+	}
+	copy(s.SetID[:], setID)
+
+	if s.Threshold, err = strconv.Atoi(parts[1]); err != nil || s.Threshold < 2 || s.Threshold > 255 {
+		return Share{}, fmt.Errorf("malformed share threshold")
+	}
+	if s.Index, err = strconv.Atoi(parts[2]); err != nil || s.Index < 1 || s.Index > 255 {
+		return Share{}, fmt.Errorf("malformed share index")
+	}
+	if s.Value, err = hex.DecodeString(parts[3]); err != nil || len(s.Value) == 0 {
+		return Share{}, fmt.Errorf("malformed share value")
+	}
+
+	return s, nil
+}
+
+func shareChecksum(body string) string {
+	sum := sha256.Sum256([]byte(body))
+	return hex.EncodeToString(sum[:4])
+}
+
+func cutLast(s, sep string) (string, string, bool) {
+	i := strings.LastIndex(s, sep)
+	if i < 0 {
+		return s, "", false
+	}
+	return s[:i], s[i+len(sep):], true
+}
+
+// evaluate evaluates the polynomial with the given coefficients, lowest degree first, at x.
+func evaluate(coeffs []byte, x byte) byte {
+	var y byte
+	for i := len(coeffs) - 1; i >= 0; i-- {
+		y = mul(y, x) ^ coeffs[i]
+	}
+	return y
+}
+
+// GF(2^8) arithmetic with the AES polynomial x^8 + x^4 + x^3 + x + 1
+
+var expTable, logTable = gfTables()
+
+func gfTables() (exp [510]byte, log [256]byte) {
+	x := byte(1)
+	for i := 0; i < 255; i++ {
+		exp[i] = x
+		exp[i+255] = x
+		log[x] = byte(i)
+		// multiply by the generator 3
+		x ^= x<<1 ^ byte(int8(x)>>7)&0x1b
+	}
+	return exp, log
+}
+
+func mul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return expTable[int(logTable[a])+int(logTable[b])]
+}
+
+func div(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	return expTable[int(logTable[a])+255-int(logTable[b])]
+}
@@ -0,0 +1,194 @@
+package shamir
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+var testSecret = []byte("correct horse battery staple")
+
+func TestGF256(t *testing.T) {
+	// FIPS 197 section 4.2 example
+	if got := mul(0x57, 0x83); got != 0xc1 {
+		t.Errorf("mul(0x57, 0x83) = %#x, want 0xc1", got)
+	}
+	for a := 1; a < 256; a++ {
+		for b := 1; b < 256; b++ {
+			if got := mul(div(byte(a), byte(b)), byte(b)); got != byte(a) {
+				t.Fatalf("div(%#x, %#x) * %#x = %#x", a, b, b, got)
+			}
+		}
+	}
+}
+
+func TestSplitCombine(t *testing.T) {
+	tests := []struct{ n, threshold int }{
+		{2, 2}, {3, 2}, {3, 3}, {5, 3}, {7, 4}, {10, 10}, {255, 2},
+	}
+
+	for _, tt := range tests {
+		shares, err := Split(testSecret, tt.n, tt.threshold)
+		if err != nil {
+			t.Fatalf("Split(%d, %d) failed: %v", tt.n, tt.threshold, err)
+		}
+		if len(shares) != tt.n {
+			t.Fatalf("Split(%d, %d) returned %d shares", tt.n, tt.threshold, len(shares))
+		}
+
+		// every window of threshold consecutive shares, in reverse order, reconstructs the secret
+		for start := 0; start+tt.threshold <= tt.n; start++ {
+			subset := make([]Share, tt.threshold)
+			for i := range subset {
+				subset[i] = shares[start+tt.threshold-1-i]
+			}
+			secret, err := Combine(subset)
+			if err != nil {
+				t.Fatalf("%d-of-%d: Combine failed: %v", tt.threshold, tt.n, err)
+			}
+			if !bytes.Equal(secret, testSecret) {
+				t.Fatalf("%d-of-%d: Combine of shares %d.. returned the wrong secret", tt.threshold, tt.n, start+1)
+			}
+		}
+
+		// more shares than needed work as well
+		secret, err := Combine(shares)
+		if err != nil || !bytes.Equal(secret, testSecret) {
+			t.Fatalf("%d-of-%d: Combine of all shares failed: %v", tt.threshold, tt.n, err)
+		}
+	}
+}
+
+func TestSplitInvalid(t *testing.T) {
+	for _, tt := range []struct{ n, threshold int }{{3, 1}, {2, 3}, {256, 2}} {
+		if _, err := Split(testSecret, tt.n, tt.threshold); err == nil {
+			t.Errorf("Split(%d, %d) succeeded, want an error", tt.n, tt.threshold)
+		}
+	}
+	if _, err := Split(nil, 3, 2); err == nil {
+		t.Error("Split of an empty secret succeeded, want an error")
+	}
+}
+
+func TestTooFewShares(t *testing.T) {
+	shares, err := Split(testSecret, 5, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Combine(shares[:2]); err == nil || !strings.Contains(err.Error(), "3 are needed") {
+		t.Fatalf("Combine of 2 of 3 needed shares: got %v, want an error", err)
+	}
+
+	// Interpolating fewer shares than the threshold does not reveal the secret either
+	subset := append([]Share{}, shares[:2]...)
+	for i := range subset {
+		subset[i].Threshold = 2
+	}
+	secret, err := Combine(subset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(secret, testSecret) {
+		t.Fatal("2 shares of a 3-of-5 split reconstructed the secret")
+	}
+}
+
+func TestShareStringRoundTrip(t *testing.T) {
+	shares, err := Split(testSecret, 4, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, share := range shares {
+		text := share.String()
+		if !strings.HasPrefix(text, sharePrefix+"-") {
+			t.Fatalf("share %q does not start with %q", text, sharePrefix)
+		}
+
+		// custodians may type the share in upper case and with whitespace
+		for _, variant := range []string{text, strings.ToUpper(text), " " + text[:20] + "\n " + text[20:] + "\t"} {
+			parsed, err := ParseShare(variant)
+			if err != nil {
+				t.Fatalf("ParseShare(%q) failed: %v", variant, err)
+			}
+			if parsed.SetID != share.SetID || parsed.Threshold != share.Threshold || parsed.Index != share.Index ||
+				!bytes.Equal(parsed.Value, share.Value) {
+				t.Fatalf("ParseShare(%q) = %+v, want %+v", variant, parsed, share)
+			}
+		}
+	}
+}
+
+func TestParseShareInvalid(t *testing.T) {
+	shares, err := Split(testSecret, 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := shares[0].String()
+
+	// flip one hex digit of the value
+	i := strings.LastIndex(text, "-") - 1
+	flipped := []byte(text)
+	if flipped[i] == '0' {
+		flipped[i] = '1'
+	} else {
+		flipped[i] = '0'
+	}
+
+	tests := []struct {
+		name    string
+		text    string
+		errText string
+	}{
+		{"bad checksum", string(flipped), "checksum mismatch"},
+		{"truncated", text[:len(text)-2], "checksum mismatch"},
+		{"not a share", "hello-world", "not a revokr share"},
+		{"empty", "", "not a revokr share"},
+		{"index zero", withChecksum(sharePrefix + "-00112233-2-0-abcd"), "malformed share index"},
+		{"threshold one", withChecksum(sharePrefix + "-00112233-1-1-abcd"), "malformed share threshold"},
+		{"short set id", withChecksum(sharePrefix + "-0011-2-1-abcd"), "malformed share set id"},
+		{"bad value", withChecksum(sharePrefix + "-00112233-2-1-xyz"), "malformed share value"},
+		{"missing field", withChecksum(sharePrefix + "-00112233-2-abcd"), "malformed share"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseShare(tt.text)
+			if err == nil || !strings.Contains(err.Error(), tt.errText) {
+				t.Fatalf("ParseShare(%q) = %v, want an error containing %q", tt.text, err, tt.errText)
+			}
+		})
+	}
+}
+
+func withChecksum(body string) string {
+	return body + "-" + shareChecksum(body)
+}
+
+func TestCombineIncompatible(t *testing.T) {
+	a, err := Split(testSecret, 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := Split(testSecret, 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Combine([]Share{a[0], b[1]}); err == nil || !strings.Contains(err.Error(), "different split") {
+		t.Errorf("Combine of shares of two splits: got %v, want a different split error", err)
+	}
+	if _, err := Combine([]Share{a[1], a[1]}); err == nil || !strings.Contains(err.Error(), "given twice") {
+		t.Errorf("Combine with a duplicate index: got %v, want a duplicate error", err)
+	}
+	if err := Compatible(a[:1], a[0]); err == nil {
+		t.Error("Compatible accepted a share already given")
+	}
+	if err := Compatible(a[:1], b[0]); err == nil {
+		t.Error("Compatible accepted a share of another split")
+	}
+	if _, err := Combine(nil); err == nil {
+		t.Error("Combine of no shares succeeded")
+	}
+}
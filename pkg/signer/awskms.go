@@ -0,0 +1,263 @@
+package signer
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// KMSSigner is a crypto.Signer backed by an asymmetric AWS KMS key, or a key of a service implementing
+// the KMS API such as local-kms. Credentials are read from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+// AWS_SESSION_TOKEN, the region from AWS_REGION or AWS_DEFAULT_REGION and the endpoint, if not the AWS
+// one, from AWS_ENDPOINT_URL_KMS or AWS_ENDPOINT_URL.
+type KMSSigner struct {
+	endpoint     string
+	region       string
+	keyID        string
+	accessKey    string
+	secretKey    string
+	sessionToken string
+	pub          crypto.PublicKey
+}
+
+// NewKMSSigner opens a KMS key given by key ID, key ARN or alias.
+func NewKMSSigner(keyID string) (*KMSSigner, error) {
+	if keyID == "" {
+		return nil, fmt.Errorf("KMS key ID is empty")
+	}
+
+	s := &KMSSigner{
+		keyID:        keyID,
+		region:       firstEnv("AWS_REGION", "AWS_DEFAULT_REGION"),
+		endpoint:     firstEnv("AWS_ENDPOINT_URL_KMS", "AWS_ENDPOINT_URL"),
+		accessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if s.region == "" {
+		return nil, fmt.Errorf("AWS_REGION is not set")
+	}
+	if s.accessKey == "" || s.secretKey == "" {
+		return nil, fmt.Errorf("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set")
+	}
+	if s.endpoint == "" {
+		s.endpoint = "https://kms." + s.region + ".amazonaws.com"
+	}
+	s.endpoint = strings.TrimRight(s.endpoint, "/")
+
+	var resp struct {
+		PublicKey []byte `json:"PublicKey"`
+		KeyUsage  string `json:"KeyUsage"`
+	}
+	if err := s.call("GetPublicKey", map[string]any{"KeyId": keyID}, &resp); err != nil {
+		return nil, err
+	}
+	if resp.KeyUsage != "" && resp.KeyUsage != "SIGN_VERIFY" {
+		return nil, fmt.Errorf("KMS key %q has usage %s, not SIGN_VERIFY", keyID, resp.KeyUsage)
+	}
+
+	pub, err := x509.ParsePKIXPublicKey(resp.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid KMS public key: %w", err)
+	}
+	s.pub = pub
+
+	log.Debug().Str("key", keyID).Str("endpoint", s.endpoint).Msgf("Using KMS key of type %T", s.pub)
+	return s, nil
+}
+
+// firstEnv returns the value of the first environment variable that is set.
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// Public returns the public key of the KMS key.
+func (s *KMSSigner) Public() crypto.PublicKey {
+	return s.pub
+}
+
+// Sign signs a digest with the KMS key. KMS PSS signatures always use a salt of the hash length.
+func (s *KMSSigner) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	var hashName string
+	switch opts.HashFunc() {
+	case crypto.SHA256:
+		hashName = "SHA_256"
+	case crypto.SHA384:
+		hashName = "SHA_384"
+	case crypto.SHA512:
+		hashName = "SHA_512"
+	default:
+		return nil, fmt.Errorf("unsupported hash algorithm %s for KMS signing", opts.HashFunc())
+	}
+
+	var alg string
+	switch s.pub.(type) {
+	case *rsa.PublicKey:
+		alg = "RSASSA_PKCS1_V1_5_" + hashName
+		if pss, ok := opts.(*rsa.PSSOptions); ok {
+			if pss.SaltLength != rsa.PSSSaltLengthEqualsHash && pss.SaltLength != opts.HashFunc().Size() {
+				return nil, fmt.Errorf("KMS signing only supports PSS salts of the hash length")
+			}
+			alg = "RSASSA_PSS_" + hashName
+		}
+	case *ecdsa.PublicKey:
+		alg = "ECDSA_" + hashName
+	default:
+		return nil, fmt.Errorf("unsupported KMS public key type %T", s.pub)
+	}
+
+	var resp struct {
+		Signature []byte `json:"Signature"`
+	}
+	err := s.call("Sign", map[string]any{
+		"KeyId":            s.keyID,
+		"Message":          digest,
+		"MessageType":      "DIGEST",
+		"SigningAlgorithm": alg,
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Signature) == 0 {
+		return nil, fmt.Errorf("KMS returned an empty signature")
+	}
+
+	return resp.Signature, nil
+}
+
+// call invokes a KMS API action and decodes the JSON response into out.
+func (s *KMSSigner) call(action string, body any, out any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to marshal KMS request: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.endpoint+"/", bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create KMS request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "TrentService."+action)
+	s.signRequest(req, data, "kms", time.Now().UTC())
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("KMS %s request failed: %w", action, err)
+	}
+	defer resp.Body.Close()
+
+	respData, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read KMS response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var kmsErr struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(respData, &kmsErr) == nil && kmsErr.Type != "" {
+			return fmt.Errorf("KMS %s returned %s: %s %s", action, resp.Status, kmsErr.Type, kmsErr.Message)
+		}
+		return fmt.Errorf("KMS %s returned %s", action, resp.Status)
+	}
+
+	if err := json.Unmarshal(respData, out); err != nil {
+		return fmt.Errorf("invalid KMS %s response: %w", action, err)
+	}
+	return nil
+}
+
+// signRequest adds an AWS Signature Version 4 Authorization header to a request to the service.
+func (s *KMSSigner) signRequest(req *http.Request, body []byte, service string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	scope := date + "/" + s.region + "/" + service + "/aws4_request"
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	if s.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.sessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hexSHA256(body),
+	}, "\n")
+
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hexSHA256([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), date)
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKey, scope, signedHeaders, signature))
+}
+
+// canonicalQuery encodes query parameters sorted by name as required by Signature Version 4.
+func canonicalQuery(query url.Values) string {
+	return strings.ReplaceAll(query.Encode(), "+", "%20")
+}
+
+func hexSHA256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,268 @@
+package signer
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSignRequestV4 checks requests of the AWS Signature Version 4 test suite.
+func TestSignRequestV4(t *testing.T) {
+	s := &KMSSigner{
+		region:    "us-east-1",
+		accessKey: "AKIDEXAMPLE",
+		secretKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+	}
+	now := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		method    string
+		url       string
+		signature string
+	}{
+		{"get-vanilla", http.MethodGet, "https://example.amazonaws.com/", "5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"},
+		{"post-vanilla", http.MethodPost, "https://example.amazonaws.com/", "5da7c1a2acd57cee7505fc6676e4e544621c30862966e37dddb68e92efbe5d6b"},
+		{"get-vanilla-query-order-key-case", http.MethodGet, "https://example.amazonaws.com/?Param2=value2&Param1=value1", "b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, tt.url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			s.signRequest(req, nil, "service", now)
+
+			if got := req.Header.Get("X-Amz-Date"); got != "20150830T123600Z" {
+				t.Errorf("X-Amz-Date = %q", got)
+			}
+			want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=" + tt.signature
+			if got := req.Header.Get("Authorization"); got != want {
+				t.Errorf("Authorization = %q\nwant %q", got, want)
+			}
+		})
+	}
+
+	// post-sts-header-before, the session token is signed
+	s.sessionToken = "AQoDYXdzEPT//////////wEXAMPLEtc764bNrC9SAPBSM22wDOk4x4HIZ8j4FZTwdQWLWsKWHGBuFqwAeMicRXmxfpSPfIeoIYRqTflfKD8YUuwthAx7mSEI/qkPpKPi/kMcGdQrmGdeehM4IC1NtBmUpp2wUE8phUZampKsburEDy0KPkyQDYwT7WZ0wq5VSXDvp75YU9HFvlRd8Tx6q6fE8YQcHNVXAkiY9q6d+xo0rKwT38xVqr7ZD0u0iPPkUL64lIZbqBAz+scqKmlzm8FDrypNC9Yjc8fPOLn9FX9KSYvKTr4rvx3iSIlTJabIQwj2ICCR/oLxBA=="
+	req, err := http.NewRequest(http.MethodPost, "https://example.amazonaws.com/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.signRequest(req, nil, "service", now)
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date;x-amz-security-token, Signature=85d96828115b5dc0cfc3bd16ad9e210dd772bbebba041836c64533a82be05ead"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("Authorization with a session token = %q\nwant %q", got, want)
+	}
+	if got := req.Header.Get("X-Amz-Security-Token"); got != s.sessionToken {
+		t.Errorf("X-Amz-Security-Token = %q", got)
+	}
+}
+
+// kmsStandIn stands in for the KMS API of local-kms or AWS holding a single key "alias/my-ca". It checks
+// the Signature Version 4 signature of every request and records the signing algorithm of the last Sign.
+func kmsStandIn(t *testing.T, key crypto.Signer, signingAlgorithm *string) *httptest.Server {
+	t.Helper()
+	verifier := &KMSSigner{region: "eu-west-1", accessKey: "AKIDEXAMPLE", secretKey: "secret", sessionToken: "token"}
+	keyID := "arn:aws:kms:eu-west-1:111122223333:key/0d8e7b6a-1c2f-4e4b-9a51-2f0c7a6e3b19"
+
+	kmsError := func(w http.ResponseWriter, status int, typ, message string) {
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		w.WriteHeader(status)
+		fmt.Fprintf(w, `{"__type": %q, "message": %q}`, typ, message)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Method != http.MethodPost || r.URL.Path != "/" || r.Header.Get("Content-Type") != "application/x-amz-json-1.1" {
+			kmsError(w, http.StatusBadRequest, "UnknownOperationException", "")
+			return
+		}
+
+		// sign the request again from the signed headers, as received
+		auth := r.Header.Get("Authorization")
+		_, signedHeaders, _ := strings.Cut(auth, "SignedHeaders=")
+		signedHeaders, _, _ = strings.Cut(signedHeaders, ",")
+		now, err := time.Parse("20060102T150405Z", r.Header.Get("X-Amz-Date"))
+		if err != nil {
+			kmsError(w, http.StatusBadRequest, "MissingAuthenticationTokenException", "missing X-Amz-Date")
+			return
+		}
+		check, _ := http.NewRequest(r.Method, "http://"+r.Host+r.URL.RequestURI(), nil)
+		for _, name := range strings.Split(signedHeaders, ";") {
+			if name != "host" && name != "x-amz-date" {
+				check.Header.Set(name, r.Header.Get(name))
+			}
+		}
+		verifier.signRequest(check, body, "kms", now)
+		if auth != check.Header.Get("Authorization") {
+			kmsError(w, http.StatusBadRequest, "InvalidSignatureException", "The request signature we calculated does not match the signature you provided.")
+			return
+		}
+
+		var req struct {
+			KeyId            string
+			Message          []byte
+			MessageType      string
+			SigningAlgorithm string
+		}
+		if err := json.Unmarshal(body, &req); err != nil {
+			kmsError(w, http.StatusBadRequest, "SerializationException", err.Error())
+			return
+		}
+		if req.KeyId != "alias/my-ca" {
+			kmsError(w, http.StatusBadRequest, "NotFoundException", fmt.Sprintf("Alias %s is not found.", req.KeyId))
+			return
+		}
+
+		var resp any
+		switch r.Header.Get("X-Amz-Target") {
+		case "TrentService.GetPublicKey":
+			spki, err := x509.MarshalPKIXPublicKey(key.Public())
+			if err != nil {
+				t.Error(err)
+			}
+			resp = map[string]any{
+				"KeyId":     keyID,
+				"KeyUsage":  "SIGN_VERIFY",
+				"PublicKey": spki,
+			}
+		case "TrentService.Sign":
+			if req.MessageType != "DIGEST" {
+				kmsError(w, http.StatusBadRequest, "ValidationException", "expected a digest")
+				return
+			}
+			*signingAlgorithm = req.SigningAlgorithm
+			hash := map[string]crypto.Hash{"256": crypto.SHA256, "384": crypto.SHA384, "512": crypto.SHA512}[req.SigningAlgorithm[len(req.SigningAlgorithm)-3:]]
+			var opts crypto.SignerOpts = hash
+			if strings.HasPrefix(req.SigningAlgorithm, "RSASSA_PSS_") {
+				opts = &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: hash}
+			}
+			signature, err := key.Sign(rand.Reader, req.Message, opts)
+			if err != nil {
+				kmsError(w, http.StatusBadRequest, "KMSInternalException", err.Error())
+				return
+			}
+			resp = map[string]any{"KeyId": keyID, "Signature": signature, "SigningAlgorithm": req.SigningAlgorithm}
+		default:
+			kmsError(w, http.StatusBadRequest, "UnknownOperationException", "")
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(srv.Close)
+
+	t.Setenv("AWS_ENDPOINT_URL_KMS", srv.URL)
+	t.Setenv("AWS_REGION", verifier.region)
+	t.Setenv("AWS_ACCESS_KEY_ID", verifier.accessKey)
+	t.Setenv("AWS_SECRET_ACCESS_KEY", verifier.secretKey)
+	t.Setenv("AWS_SESSION_TOKEN", verifier.sessionToken)
+	return srv
+}
+
+func TestKMSSigner(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		key              crypto.Signer
+		sigAlg           x509.SignatureAlgorithm
+		signingAlgorithm string
+	}{
+		{ecKey, x509.ECDSAWithSHA256, "ECDSA_SHA_256"},
+		{p384Key, x509.ECDSAWithSHA384, "ECDSA_SHA_384"},
+		{rsaKey, x509.SHA256WithRSAPSS, "RSASSA_PSS_SHA_256"},
+		{rsaKey, x509.SHA512WithRSAPSS, "RSASSA_PSS_SHA_512"},
+		{rsaKey, x509.SHA256WithRSA, "RSASSA_PKCS1_V1_5_SHA_256"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.signingAlgorithm, func(t *testing.T) {
+			var signingAlgorithm string
+			kmsStandIn(t, tt.key, &signingAlgorithm)
+
+			s, err := Open("awskms:alias/my-ca")
+			if err != nil {
+				t.Fatalf("failed to open KMS signer: %v", err)
+			}
+			if !tt.key.Public().(interface{ Equal(crypto.PublicKey) bool }).Equal(s.Public()) {
+				t.Fatal("public key read from KMS does not match")
+			}
+
+			crt := selfSignedCA(t, s, tt.sigAlg)
+			der, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+				SignatureAlgorithm: tt.sigAlg,
+				Number:             big.NewInt(1),
+				ThisUpdate:         time.Now(),
+				NextUpdate:         time.Now().Add(24 * time.Hour),
+			}, crt, s)
+			if err != nil {
+				t.Fatalf("failed to sign CRL: %v", err)
+			}
+			if signingAlgorithm != tt.signingAlgorithm {
+				t.Errorf("signing algorithm = %s, want %s", signingAlgorithm, tt.signingAlgorithm)
+			}
+			rl, err := x509.ParseRevocationList(der)
+			if err != nil {
+				t.Fatalf("failed to parse CRL: %v", err)
+			}
+			if err := rl.CheckSignatureFrom(crt); err != nil {
+				t.Fatalf("CRL signature does not verify: %v", err)
+			}
+		})
+	}
+}
+
+func TestKMSSignerErrors(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var signingAlgorithm string
+	kmsStandIn(t, key, &signingAlgorithm)
+
+	if _, err := Open("awskms:alias/other"); err == nil || !strings.Contains(err.Error(), "NotFoundException") {
+		t.Errorf("unknown key: got %v, want a NotFoundException", err)
+	}
+
+	s, err := Open("awskms:alias/my-ca")
+	if err != nil {
+		t.Fatalf("failed to open KMS signer: %v", err)
+	}
+	digest := make([]byte, 32)
+	if _, err := s.Sign(rand.Reader, digest, &rsa.PSSOptions{SaltLength: 20, Hash: crypto.SHA256}); err == nil {
+		t.Error("PSS with a salt shorter than the hash succeeded")
+	}
+	if _, err := s.Sign(rand.Reader, make([]byte, 20), crypto.SHA1); err == nil {
+		t.Error("SHA-1 succeeded")
+	}
+
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "wrong")
+	if _, err := Open("awskms:alias/my-ca"); err == nil || !strings.Contains(err.Error(), "InvalidSignatureException") {
+		t.Errorf("wrong secret key: got %v, want an InvalidSignatureException", err)
+	}
+}
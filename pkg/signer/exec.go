@@ -0,0 +1,160 @@
+package signer
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/goodieshq/revokr/pkg/util"
+	"github.com/rs/zerolog/log"
+)
+
+// ExecProtocolVersion is the version of the JSON protocol spoken with signer plugins.
+const ExecProtocolVersion = 1
+
+// Operations of the signer plugin protocol.
+const (
+	OperationGetPublicKey = "get-public-key"
+	OperationSignDigest   = "sign-digest"
+)
+
+// Paddings requested from signer plugins.
+const (
+	PaddingPKCS1v15 = "pkcs1v15" // RSA PKCS#1 v1.5, the plugin builds the DigestInfo
+	PaddingPSS      = "pss"      // RSASSA-PSS with MGF1 using the same hash
+	PaddingNone     = "none"     // ECDSA and Ed25519
+)
+
+// ExecRequest is written as JSON to the stdin of a signer plugin.
+type ExecRequest struct {
+	Version    int    `json:"version"`
+	Operation  string `json:"operation"`
+	Digest     []byte `json:"digest,omitempty"`      // base64; the full message for Ed25519
+	Hash       string `json:"hash,omitempty"`        // e.g. "SHA-256", "none" for Ed25519
+	Padding    string `json:"padding,omitempty"`     // pkcs1v15, pss or none
+	SaltLength int    `json:"salt_length,omitempty"` // PSS salt length in bytes
+}
+
+// ExecResponse is read as JSON from the stdout of a signer plugin.
+type ExecResponse struct {
+	PublicKey []byte `json:"public_key,omitempty"` // base64 DER SubjectPublicKeyInfo
+	Signature []byte `json:"signature,omitempty"`  // base64; ECDSA as DER or r||s
+	Error     string `json:"error,omitempty"`
+}
+
+// ExecSigner is a crypto.Signer that delegates to an external plugin program. The plugin is spawned once
+// per request, reads a single ExecRequest from stdin and writes a single ExecResponse to stdout. Its
+// stderr is passed through so it can talk to the user.
+type ExecSigner struct {
+	command string
+	pub     crypto.PublicKey
+}
+
+// NewExecSigner runs the plugin command line to fetch its public key. The command line is run with
+// util.ShellCommand, i.e. /bin/sh -c or cmd.exe /C on Windows, so paths containing spaces must be quoted
+// as they would be in that shell.
+func NewExecSigner(command string) (*ExecSigner, error) {
+	if strings.TrimSpace(command) == "" {
+		return nil, fmt.Errorf("signer plugin command is empty")
+	}
+
+	s := &ExecSigner{command: command}
+
+	resp, err := s.call(&ExecRequest{Operation: OperationGetPublicKey})
+	if err != nil {
+		return nil, err
+	}
+	if s.pub, err = x509.ParsePKIXPublicKey(resp.PublicKey); err != nil {
+		return nil, fmt.Errorf("signer plugin returned an invalid public key: %w", err)
+	}
+
+	log.Debug().Str("plugin", command).Msgf("Using signer plugin with key of type %T", s.pub)
+	return s, nil
+}
+
+// Public returns the public key reported by the plugin.
+func (s *ExecSigner) Public() crypto.PublicKey {
+	return s.pub
+}
+
+// Sign asks the plugin to sign a digest, or the message itself for Ed25519.
+func (s *ExecSigner) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	req := &ExecRequest{
+		Operation: OperationSignDigest,
+		Digest:    digest,
+		Hash:      "none",
+		Padding:   PaddingNone,
+	}
+	if h := opts.HashFunc(); h != 0 {
+		req.Hash = h.String()
+	}
+
+	switch s.pub.(type) {
+	case *rsa.PublicKey:
+		req.Padding = PaddingPKCS1v15
+		if pss, ok := opts.(*rsa.PSSOptions); ok {
+			req.Padding = PaddingPSS
+			req.SaltLength = pss.SaltLength
+			if req.SaltLength == rsa.PSSSaltLengthEqualsHash || req.SaltLength == rsa.PSSSaltLengthAuto {
+				req.SaltLength = opts.HashFunc().Size()
+			}
+		}
+	case *ecdsa.PublicKey, ed25519.PublicKey:
+	default:
+		return nil, fmt.Errorf("unsupported signer plugin public key type %T", s.pub)
+	}
+
+	resp, err := s.call(req)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Signature) == 0 {
+		return nil, fmt.Errorf("signer plugin returned an empty signature")
+	}
+
+	if pub, ok := s.pub.(*ecdsa.PublicKey); ok {
+		return util.ECDSASignatureToDER(resp.Signature, pub)
+	}
+	return resp.Signature, nil
+}
+
+// call runs the plugin with a single request and returns its response.
+func (s *ExecSigner) call(req *ExecRequest) (*ExecResponse, error) {
+	req.Version = ExecProtocolVersion
+	input, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal signer plugin request: %w", err)
+	}
+
+	var stdout bytes.Buffer
+	cmd := util.ShellCommand(s.command)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+
+	runErr := cmd.Run()
+
+	var resp ExecResponse
+	if err := json.Unmarshal(stdout.Bytes(), &resp); err != nil {
+		if runErr != nil {
+			return nil, fmt.Errorf("signer plugin %s failed: %w", req.Operation, runErr)
+		}
+		return nil, fmt.Errorf("signer plugin returned an invalid response to %s: %w", req.Operation, err)
+	}
+	if resp.Error != "" {
+		return nil, fmt.Errorf("signer plugin %s failed: %s", req.Operation, resp.Error)
+	}
+	if runErr != nil {
+		return nil, fmt.Errorf("signer plugin %s failed: %w", req.Operation, runErr)
+	}
+
+	return &resp, nil
+}
@@ -0,0 +1,243 @@
+package signer
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/goodieshq/revokr/pkg/util"
+	"golang.org/x/crypto/cryptobyte"
+	cryptobyte_asn1 "golang.org/x/crypto/cryptobyte/asn1"
+)
+
+func TestNewExecSignerPathWithSpaces(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the plugin is a POSIX shell script")
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spki, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := filepath.Join(t.TempDir(), "My Signer")
+	if err := os.Mkdir(dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	plugin := filepath.Join(dir, "plugin")
+	script := fmt.Sprintf("#!/bin/sh\ncat >/dev/null\n[ \"$1\" = \"--slot\" ] || exit 1\necho '{\"public_key\": \"%s\"}'\n", base64.StdEncoding.EncodeToString(spki))
+	if err := os.WriteFile(plugin, []byte(script), 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewExecSigner("'" + plugin + "' --slot 1")
+	if err != nil {
+		t.Fatalf("failed to run signer plugin: %v", err)
+	}
+	if !key.PublicKey.Equal(s.Public()) {
+		t.Error("public key of the plugin does not match")
+	}
+}
+
+// TestExecSignerPlugin is not a test but the signer plugin run by TestExecSignerSign, a copy of the test
+// binary with REVOKR_TEST_PLUGIN_KEY set to a PKCS#8 key file.
+func TestExecSignerPlugin(t *testing.T) {
+	keyPath := os.Getenv("REVOKR_TEST_PLUGIN_KEY")
+	if keyPath == "" {
+		return
+	}
+
+	resp := runTestPlugin(keyPath)
+	if err := json.NewEncoder(os.Stdout).Encode(resp); err != nil {
+		os.Exit(2)
+	}
+	if resp.Error != "" {
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func runTestPlugin(keyPath string) *ExecResponse {
+	if msg := os.Getenv("REVOKR_TEST_PLUGIN_ERROR"); msg != "" {
+		return &ExecResponse{Error: msg}
+	}
+
+	var req ExecRequest
+	if err := json.NewDecoder(os.Stdin).Decode(&req); err != nil {
+		return &ExecResponse{Error: err.Error()}
+	}
+	if req.Version != ExecProtocolVersion {
+		return &ExecResponse{Error: fmt.Sprintf("unsupported protocol version %d", req.Version)}
+	}
+	signer, err := util.ParsePrivateSigner(keyPath, "")
+	if err != nil {
+		return &ExecResponse{Error: err.Error()}
+	}
+
+	switch req.Operation {
+	case OperationGetPublicKey:
+		spki, err := x509.MarshalPKIXPublicKey(signer.Public())
+		if err != nil {
+			return &ExecResponse{Error: err.Error()}
+		}
+		return &ExecResponse{PublicKey: spki}
+
+	case OperationSignDigest:
+		var opts crypto.SignerOpts = crypto.Hash(0)
+		for _, h := range []crypto.Hash{crypto.SHA256, crypto.SHA384, crypto.SHA512} {
+			if req.Hash == h.String() {
+				opts = h
+			}
+		}
+		if req.Padding == PaddingPSS {
+			opts = &rsa.PSSOptions{SaltLength: req.SaltLength, Hash: opts.HashFunc()}
+		}
+		sig, err := signer.Sign(rand.Reader, req.Digest, opts)
+		if err != nil {
+			return &ExecResponse{Error: err.Error()}
+		}
+		// plugins backed by PKCS#11 return ECDSA signatures as r||s
+		if key, ok := signer.(*ecdsa.PrivateKey); ok {
+			var inner cryptobyte.String
+			var r, s []byte
+			input := cryptobyte.String(sig)
+			if !input.ReadASN1(&inner, cryptobyte_asn1.SEQUENCE) || !inner.ReadASN1Integer(&r) || !inner.ReadASN1Integer(&s) {
+				return &ExecResponse{Error: "failed to parse ECDSA signature"}
+			}
+			size := (key.Curve.Params().BitSize + 7) / 8
+			sig = append(new(big.Int).SetBytes(r).FillBytes(make([]byte, size)), new(big.Int).SetBytes(s).FillBytes(make([]byte, size))...)
+		}
+		return &ExecResponse{Signature: sig}
+
+	default:
+		return &ExecResponse{Error: fmt.Sprintf("unsupported operation %q", req.Operation)}
+	}
+}
+
+// testPluginCommand returns the command line running TestExecSignerPlugin with key, and sets the
+// environment of the plugin.
+func testPluginCommand(t *testing.T, key crypto.Signer) string {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "key.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("REVOKR_TEST_PLUGIN_KEY", path)
+	return `"` + os.Args[0] + `" -test.run=^TestExecSignerPlugin$`
+}
+
+func TestExecSignerSign(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		key  crypto.Signer
+		opts crypto.SignerOpts
+	}{
+		{"ecdsa", ecKey, crypto.SHA384},
+		{"rsa pkcs1v15", rsaKey, crypto.SHA256},
+		{"rsa pss", rsaKey, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA256}},
+		{"ed25519", edKey, crypto.Hash(0)},
+	}
+
+	message := []byte("revokr test CRL")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewExecSigner(testPluginCommand(t, tt.key))
+			if err != nil {
+				t.Fatalf("failed to run signer plugin: %v", err)
+			}
+
+			digest := message
+			if h := tt.opts.HashFunc(); h != 0 {
+				hash := h.New()
+				hash.Write(message)
+				digest = hash.Sum(nil)
+			}
+			sig, err := s.Sign(rand.Reader, digest, tt.opts)
+			if err != nil {
+				t.Fatalf("Sign failed: %v", err)
+			}
+
+			var valid bool
+			switch pub := s.Public().(type) {
+			case *ecdsa.PublicKey:
+				valid = ecdsa.VerifyASN1(pub, digest, sig)
+			case *rsa.PublicKey:
+				if pss, ok := tt.opts.(*rsa.PSSOptions); ok {
+					valid = rsa.VerifyPSS(pub, pss.Hash, digest, sig, pss) == nil
+				} else {
+					valid = rsa.VerifyPKCS1v15(pub, tt.opts.HashFunc(), digest, sig) == nil
+				}
+			case ed25519.PublicKey:
+				valid = ed25519.Verify(pub, message, sig)
+			}
+			if !valid {
+				t.Error("signature of the plugin does not verify")
+			}
+		})
+	}
+}
+
+func TestExecSignerErrors(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	command := testPluginCommand(t, key)
+	s, err := NewExecSigner(command)
+	if err != nil {
+		t.Fatalf("failed to run signer plugin: %v", err)
+	}
+
+	// the test framework prints the name of the test before the response
+	if _, err := NewExecSigner(command + " -test.v"); err == nil || !strings.Contains(err.Error(), "invalid response") {
+		t.Errorf("NewExecSigner with a response that is not JSON: got %v, want an invalid response error", err)
+	}
+
+	t.Setenv("REVOKR_TEST_PLUGIN_ERROR", "token is locked")
+	digest := make([]byte, 32)
+	if _, err := s.Sign(rand.Reader, digest, crypto.SHA256); err == nil || !strings.Contains(err.Error(), "token is locked") {
+		t.Errorf("Sign with an error response: got %v, want the plugin error", err)
+	}
+	if _, err := NewExecSigner(command); err == nil || !strings.Contains(err.Error(), "token is locked") {
+		t.Errorf("NewExecSigner with an error response: got %v, want the plugin error", err)
+	}
+
+	if _, err := NewExecSigner(" "); err == nil {
+		t.Error("NewExecSigner with an empty command succeeded")
+	}
+}
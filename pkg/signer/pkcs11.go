@@ -0,0 +1,310 @@
+package signer
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+	"sync"
+
+	"github.com/goodieshq/revokr/pkg/util"
+	"github.com/miekg/pkcs11"
+	"github.com/rs/zerolog/log"
+)
+
+// PKCS11Config selects a private key on a PKCS#11 token.
+type PKCS11Config struct {
+	Module     string // path to the PKCS#11 module, e.g. /usr/lib/softhsm/libsofthsm2.so
+	TokenLabel string // may be empty if only one token is present
+	KeyLabel   string // CKA_LABEL of the private key
+	KeyID      []byte // CKA_ID of the private key
+	PIN        string // user PIN of the token
+}
+
+// PKCS11Signer is a crypto.Signer backed by an RSA or ECDSA private key on a PKCS#11 token.
+type PKCS11Signer struct {
+	ctx     *pkcs11.Ctx
+	session pkcs11.SessionHandle
+	key     pkcs11.ObjectHandle
+	pub     crypto.PublicKey
+
+	mu sync.Mutex // PKCS#11 sessions must not be used concurrently
+}
+
+// pkcs11Hashes maps hash functions to their PKCS#11 mechanism and MGF1 generator.
+var pkcs11Hashes = map[crypto.Hash]struct{ mech, mgf uint }{
+	crypto.SHA256: {pkcs11.CKM_SHA256, pkcs11.CKG_MGF1_SHA256},
+	crypto.SHA384: {pkcs11.CKM_SHA384, pkcs11.CKG_MGF1_SHA384},
+	crypto.SHA512: {pkcs11.CKM_SHA512, pkcs11.CKG_MGF1_SHA512},
+}
+
+// pkcs11Curves maps the named curve OIDs of CKA_EC_PARAMS to curves.
+var pkcs11Curves = map[string]elliptic.Curve{
+	"1.2.840.10045.3.1.7": elliptic.P256(),
+	"1.3.132.0.34":        elliptic.P384(),
+	"1.3.132.0.35":        elliptic.P521(),
+}
+
+// OpenPKCS11 loads the PKCS#11 module, logs into the token and finds the private key and its public key.
+// The signer must be closed to log out of the token.
+func OpenPKCS11(cfg PKCS11Config) (*PKCS11Signer, error) {
+	if cfg.KeyLabel == "" && len(cfg.KeyID) == 0 {
+		return nil, fmt.Errorf("a key label or key ID is required to select the PKCS#11 key")
+	}
+
+	ctx := pkcs11.New(cfg.Module)
+	if ctx == nil {
+		return nil, fmt.Errorf("failed to load PKCS#11 module %q", cfg.Module)
+	}
+
+	if err := ctx.Initialize(); err != nil && !errors.Is(err, pkcs11.Error(pkcs11.CKR_CRYPTOKI_ALREADY_INITIALIZED)) {
+		ctx.Destroy()
+		return nil, fmt.Errorf("failed to initialize PKCS#11 module: %w", err)
+	}
+
+	s := &PKCS11Signer{ctx: ctx}
+	if err := s.open(cfg); err != nil {
+		s.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *PKCS11Signer) open(cfg PKCS11Config) error {
+	slot, err := findSlot(s.ctx, cfg.TokenLabel)
+	if err != nil {
+		return err
+	}
+
+	s.session, err = s.ctx.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION)
+	if err != nil {
+		return fmt.Errorf("failed to open PKCS#11 session: %w", err)
+	}
+
+	err = s.ctx.Login(s.session, pkcs11.CKU_USER, cfg.PIN)
+	if err != nil && !errors.Is(err, pkcs11.Error(pkcs11.CKR_USER_ALREADY_LOGGED_IN)) {
+		return fmt.Errorf("failed to log into PKCS#11 token: %w", err)
+	}
+
+	if s.key, err = s.findObject(pkcs11.CKO_PRIVATE_KEY, cfg); err != nil {
+		return fmt.Errorf("failed to find private key: %w", err)
+	}
+	pubKey, err := s.findObject(pkcs11.CKO_PUBLIC_KEY, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to find public key: %w", err)
+	}
+	if s.pub, err = s.readPublicKey(pubKey); err != nil {
+		return err
+	}
+
+	log.Debug().Str("token", cfg.TokenLabel).Str("key", cfg.KeyLabel).Msgf("Using PKCS#11 key of type %T", s.pub)
+	return nil
+}
+
+// findSlot returns the slot of the token with the given label, or the only token if no label is given.
+func findSlot(ctx *pkcs11.Ctx, label string) (uint, error) {
+	slots, err := ctx.GetSlotList(true)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list PKCS#11 slots: %w", err)
+	}
+
+	if label == "" {
+		if len(slots) != 1 {
+			return 0, fmt.Errorf("found %d PKCS#11 tokens, a token label is required", len(slots))
+		}
+		return slots[0], nil
+	}
+
+	for _, slot := range slots {
+		info, err := ctx.GetTokenInfo(slot)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read PKCS#11 token info: %w", err)
+		}
+		if strings.TrimRight(info.Label, " \x00") == label {
+			return slot, nil
+		}
+	}
+	return 0, fmt.Errorf("PKCS#11 token %q not found", label)
+}
+
+// findObject finds exactly one object of the given class matching the key label and ID.
+func (s *PKCS11Signer) findObject(class uint, cfg PKCS11Config) (pkcs11.ObjectHandle, error) {
+	template := []*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_CLASS, class)}
+	if cfg.KeyLabel != "" {
+		template = append(template, pkcs11.NewAttribute(pkcs11.CKA_LABEL, cfg.KeyLabel))
+	}
+	if len(cfg.KeyID) > 0 {
+		template = append(template, pkcs11.NewAttribute(pkcs11.CKA_ID, cfg.KeyID))
+	}
+
+	if err := s.ctx.FindObjectsInit(s.session, template); err != nil {
+		return 0, err
+	}
+	objects, _, err := s.ctx.FindObjects(s.session, 2)
+	if err != nil {
+		s.ctx.FindObjectsFinal(s.session)
+		return 0, err
+	}
+	if err := s.ctx.FindObjectsFinal(s.session); err != nil {
+		return 0, err
+	}
+
+	switch len(objects) {
+	case 0:
+		return 0, fmt.Errorf("no matching object on the token")
+	case 1:
+		return objects[0], nil
+	default:
+		return 0, fmt.Errorf("more than one matching object on the token, select the key by label and ID")
+	}
+}
+
+// readPublicKey reads an RSA or EC public key object.
+func (s *PKCS11Signer) readPublicKey(obj pkcs11.ObjectHandle) (crypto.PublicKey, error) {
+	attrs, err := s.ctx.GetAttributeValue(s.session, obj, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, nil),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read public key type: %w", err)
+	}
+
+	switch keyType := ulong(attrs[0].Value); keyType {
+	case pkcs11.CKK_RSA:
+		attrs, err := s.ctx.GetAttributeValue(s.session, obj, []*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_MODULUS, nil),
+			pkcs11.NewAttribute(pkcs11.CKA_PUBLIC_EXPONENT, nil),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read RSA public key: %w", err)
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(attrs[0].Value),
+			E: int(new(big.Int).SetBytes(attrs[1].Value).Int64()),
+		}, nil
+
+	case pkcs11.CKK_EC:
+		attrs, err := s.ctx.GetAttributeValue(s.session, obj, []*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, nil),
+			pkcs11.NewAttribute(pkcs11.CKA_EC_POINT, nil),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read EC public key: %w", err)
+		}
+
+		var oid asn1.ObjectIdentifier
+		if _, err := asn1.Unmarshal(attrs[0].Value, &oid); err != nil {
+			return nil, fmt.Errorf("unsupported EC parameters: %w", err)
+		}
+		curve, ok := pkcs11Curves[oid.String()]
+		if !ok {
+			return nil, fmt.Errorf("unsupported EC curve %s", oid)
+		}
+
+		// CKA_EC_POINT is a DER OCTET STRING, but some modules return the bare point
+		point := attrs[1].Value
+		var wrapped []byte
+		if rest, err := asn1.Unmarshal(point, &wrapped); err == nil && len(rest) == 0 {
+			point = wrapped
+		}
+		return ecdsa.ParseUncompressedPublicKey(curve, point)
+
+	default:
+		return nil, fmt.Errorf("unsupported PKCS#11 key type %d", keyType)
+	}
+}
+
+// ulong decodes a CK_ULONG attribute value, which is stored in native byte order.
+func ulong(b []byte) uint64 {
+	switch len(b) {
+	case 8:
+		return binary.NativeEndian.Uint64(b)
+	case 4:
+		return uint64(binary.NativeEndian.Uint32(b))
+	}
+	return ^uint64(0)
+}
+
+// Public returns the public key of the token key.
+func (s *PKCS11Signer) Public() crypto.PublicKey {
+	return s.pub
+}
+
+// Sign signs a digest with the token key. RSA keys sign PKCS#1 v1.5 or, with rsa.PSSOptions, RSASSA-PSS
+// signatures. ECDSA signatures are returned in DER form.
+func (s *PKCS11Signer) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	h := opts.HashFunc()
+	hash, ok := pkcs11Hashes[h]
+	if !ok {
+		return nil, fmt.Errorf("unsupported hash algorithm %s for PKCS#11 signing", h)
+	}
+	if len(digest) != h.Size() {
+		return nil, fmt.Errorf("digest is %d bytes, expected %d bytes for %s", len(digest), h.Size(), h)
+	}
+
+	var mech *pkcs11.Mechanism
+	data := digest
+
+	switch pub := s.pub.(type) {
+	case *rsa.PublicKey:
+		if pss, ok := opts.(*rsa.PSSOptions); ok {
+			saltLength := pss.SaltLength
+			if saltLength == rsa.PSSSaltLengthEqualsHash || saltLength == rsa.PSSSaltLengthAuto {
+				saltLength = h.Size()
+			}
+			mech = pkcs11.NewMechanism(pkcs11.CKM_RSA_PKCS_PSS, pkcs11.NewPSSParams(hash.mech, hash.mgf, uint(saltLength)))
+		} else {
+			info, err := util.MarshalDigestInfo(h, digest)
+			if err != nil {
+				return nil, err
+			}
+			mech = pkcs11.NewMechanism(pkcs11.CKM_RSA_PKCS, nil)
+			data = info
+		}
+
+	case *ecdsa.PublicKey:
+		mech = pkcs11.NewMechanism(pkcs11.CKM_ECDSA, nil)
+		signature, err := s.sign(mech, data)
+		if err != nil {
+			return nil, err
+		}
+		return util.RawECDSASignatureToDER(signature, pub)
+
+	default:
+		return nil, fmt.Errorf("unsupported PKCS#11 public key type %T", s.pub)
+	}
+
+	return s.sign(mech, data)
+}
+
+func (s *PKCS11Signer) sign(mech *pkcs11.Mechanism, data []byte) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.ctx.SignInit(s.session, []*pkcs11.Mechanism{mech}, s.key); err != nil {
+		return nil, fmt.Errorf("failed to initialize PKCS#11 signature: %w", err)
+	}
+	signature, err := s.ctx.Sign(s.session, data)
+	if err != nil {
+		return nil, fmt.Errorf("PKCS#11 signing failed: %w", err)
+	}
+	return signature, nil
+}
+
+// Close logs out of the token and unloads the PKCS#11 module.
+func (s *PKCS11Signer) Close() error {
+	if s.session != 0 {
+		s.ctx.Logout(s.session)
+		s.ctx.CloseSession(s.session)
+	}
+	s.ctx.Finalize()
+	s.ctx.Destroy()
+	return nil
+}
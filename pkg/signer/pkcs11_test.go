@@ -0,0 +1,205 @@
+package signer
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/miekg/pkcs11"
+)
+
+const (
+	softHSMTokenLabel = "revokr-test"
+	softHSMUserPIN    = "1234"
+	softHSMSOPIN      = "5678"
+)
+
+// setupSoftHSM initializes a fresh SoftHSM2 token in a temporary directory and generates an RSA key
+// labeled "rsa" and a P-256 key labeled "ecdsa". It returns the path of the SoftHSM2 module, which
+// is taken from SOFTHSM2_MODULE; the test is skipped if it is not set.
+func setupSoftHSM(t *testing.T) string {
+	t.Helper()
+
+	module := os.Getenv("SOFTHSM2_MODULE")
+	if module == "" {
+		t.Skip("SOFTHSM2_MODULE is not set")
+	}
+
+	dir := t.TempDir()
+	tokens := filepath.Join(dir, "tokens")
+	if err := os.Mkdir(tokens, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	conf := filepath.Join(dir, "softhsm2.conf")
+	if err := os.WriteFile(conf, []byte(fmt.Sprintf("directories.tokendir = %s\nobjectstore.backend = file\n", tokens)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SOFTHSM2_CONF", conf)
+
+	ctx := pkcs11.New(module)
+	if ctx == nil {
+		t.Fatalf("failed to load PKCS#11 module %q", module)
+	}
+	defer ctx.Destroy()
+	if err := ctx.Initialize(); err != nil {
+		t.Fatalf("failed to initialize PKCS#11 module: %v", err)
+	}
+	defer ctx.Finalize()
+
+	// A fresh SoftHSM2 store has a single slot with an uninitialized token
+	slots, err := ctx.GetSlotList(false)
+	if err != nil || len(slots) == 0 {
+		t.Fatalf("failed to list slots: %v", err)
+	}
+	if err := ctx.InitToken(slots[0], softHSMSOPIN, softHSMTokenLabel); err != nil {
+		t.Fatalf("failed to initialize token: %v", err)
+	}
+
+	// SoftHSM2 reassigns the slot of an initialized token
+	slot, err := findSlot(ctx, softHSMTokenLabel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session, err := ctx.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION|pkcs11.CKF_RW_SESSION)
+	if err != nil {
+		t.Fatalf("failed to open session: %v", err)
+	}
+	defer ctx.CloseSession(session)
+
+	if err := ctx.Login(session, pkcs11.CKU_SO, softHSMSOPIN); err != nil {
+		t.Fatalf("failed to log in as SO: %v", err)
+	}
+	if err := ctx.InitPIN(session, softHSMUserPIN); err != nil {
+		t.Fatalf("failed to set user PIN: %v", err)
+	}
+	ctx.Logout(session)
+	if err := ctx.Login(session, pkcs11.CKU_USER, softHSMUserPIN); err != nil {
+		t.Fatalf("failed to log in as user: %v", err)
+	}
+	defer ctx.Logout(session)
+
+	keyAttributes := func(label string, private bool) []*pkcs11.Attribute {
+		attrs := []*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+			pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
+			pkcs11.NewAttribute(pkcs11.CKA_ID, []byte(label)),
+		}
+		if private {
+			return append(attrs,
+				pkcs11.NewAttribute(pkcs11.CKA_PRIVATE, true),
+				pkcs11.NewAttribute(pkcs11.CKA_SENSITIVE, true),
+				pkcs11.NewAttribute(pkcs11.CKA_SIGN, true),
+			)
+		}
+		return append(attrs, pkcs11.NewAttribute(pkcs11.CKA_VERIFY, true))
+	}
+
+	_, _, err = ctx.GenerateKeyPair(session,
+		[]*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_RSA_PKCS_KEY_PAIR_GEN, nil)},
+		append(keyAttributes("rsa", false),
+			pkcs11.NewAttribute(pkcs11.CKA_MODULUS_BITS, 2048),
+			pkcs11.NewAttribute(pkcs11.CKA_PUBLIC_EXPONENT, []byte{1, 0, 1}),
+		),
+		keyAttributes("rsa", true),
+	)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %v", err)
+	}
+
+	p256, _ := asn1.Marshal(asn1.ObjectIdentifier{1, 2, 840, 10045, 3, 1, 7})
+	_, _, err = ctx.GenerateKeyPair(session,
+		[]*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_EC_KEY_PAIR_GEN, nil)},
+		append(keyAttributes("ecdsa", false), pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, p256)),
+		keyAttributes("ecdsa", true),
+	)
+	if err != nil {
+		t.Fatalf("failed to generate ECDSA key: %v", err)
+	}
+
+	return module
+}
+
+func TestPKCS11SignerSoftHSM(t *testing.T) {
+	module := setupSoftHSM(t)
+
+	tests := []struct {
+		label  string
+		sigAlg x509.SignatureAlgorithm
+	}{
+		{"rsa", x509.SHA256WithRSA},
+		{"rsa", x509.SHA384WithRSAPSS},
+		{"ecdsa", x509.ECDSAWithSHA256},
+		{"ecdsa", x509.ECDSAWithSHA384},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.label+"/"+tt.sigAlg.String(), func(t *testing.T) {
+			s, err := OpenPKCS11(PKCS11Config{
+				Module:     module,
+				TokenLabel: softHSMTokenLabel,
+				KeyLabel:   tt.label,
+				PIN:        softHSMUserPIN,
+			})
+			if err != nil {
+				t.Fatalf("failed to open PKCS#11 signer: %v", err)
+			}
+			defer s.Close()
+
+			crt := selfSignedCA(t, s, tt.sigAlg)
+
+			der, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+				SignatureAlgorithm: tt.sigAlg,
+				Number:             big.NewInt(1),
+				ThisUpdate:         time.Now(),
+				NextUpdate:         time.Now().Add(24 * time.Hour),
+				RevokedCertificateEntries: []x509.RevocationListEntry{
+					{SerialNumber: big.NewInt(0x1234), RevocationTime: time.Now()},
+				},
+			}, crt, s)
+			if err != nil {
+				t.Fatalf("failed to sign CRL: %v", err)
+			}
+
+			rl, err := x509.ParseRevocationList(der)
+			if err != nil {
+				t.Fatalf("failed to parse CRL: %v", err)
+			}
+			if err := rl.CheckSignatureFrom(crt); err != nil {
+				t.Fatalf("CRL signature does not verify: %v", err)
+			}
+		})
+	}
+}
+
+// selfSignedCA issues a CA certificate for the signer's key, signed by the signer itself.
+func selfSignedCA(t *testing.T, s crypto.Signer, sigAlg x509.SignatureAlgorithm) *x509.Certificate {
+	t.Helper()
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "revokr PKCS#11 test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		SignatureAlgorithm:    sigAlg,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, s.Public(), s)
+	if err != nil {
+		t.Fatalf("failed to create CA certificate: %v", err)
+	}
+	crt, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("failed to parse CA certificate: %v", err)
+	}
+	return crt
+}
@@ -0,0 +1,35 @@
+package signer
+
+import (
+	"crypto"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// httpClient is used by the signers calling remote key management services.
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// Open returns the signer described by a "<scheme>:<config>" spec. Supported schemes:
+//
+//	exec:<command>                   an external plugin speaking the JSON protocol of ExecSigner
+//	vault:[<address>/]<mount>/<key>  a HashiCorp Vault Transit key, see VaultSigner
+//	awskms:<key-id>                  an AWS KMS key or a key of a KMS compatible service, see KMSSigner
+func Open(spec string) (crypto.Signer, error) {
+	scheme, config, ok := strings.Cut(spec, ":")
+	if !ok {
+		return nil, fmt.Errorf("invalid signer %q, expected <scheme>:<config>", spec)
+	}
+
+	switch scheme {
+	case "exec":
+		return NewExecSigner(config)
+	case "vault":
+		return NewVaultSigner(config)
+	case "awskms":
+		return NewKMSSigner(config)
+	default:
+		return nil, fmt.Errorf("unsupported signer scheme %q", scheme)
+	}
+}
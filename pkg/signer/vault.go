@@ -0,0 +1,238 @@
+package signer
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// vaultHashes maps hash functions to Vault Transit hash algorithm names.
+var vaultHashes = map[crypto.Hash]string{
+	crypto.SHA256: "sha2-256",
+	crypto.SHA384: "sha2-384",
+	crypto.SHA512: "sha2-512",
+}
+
+// VaultSigner is a crypto.Signer backed by a HashiCorp Vault Transit key. The token is read from the
+// VAULT_TOKEN environment variable and the namespace, if any, from VAULT_NAMESPACE.
+type VaultSigner struct {
+	address string // e.g. https://vault:8200
+	mount   string // mount path of the transit secrets engine
+	name    string // name of the transit key
+	token   string
+	version int // latest key version, signatures are made with it
+	pub     crypto.PublicKey
+}
+
+// NewVaultSigner opens a transit key given as "<address>/<mount>/<key>" or, using VAULT_ADDR,
+// as "<mount>/<key>".
+func NewVaultSigner(config string) (*VaultSigner, error) {
+	address := os.Getenv("VAULT_ADDR")
+	path := config
+	if strings.Contains(config, "://") {
+		u, err := url.Parse(config)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Vault key URL: %w", err)
+		}
+		path = u.Path
+		u.Path = ""
+		address = u.String()
+	}
+	if address == "" {
+		return nil, fmt.Errorf("Vault address must be part of the key URL or set in VAULT_ADDR")
+	}
+
+	path = strings.Trim(path, "/")
+	i := strings.LastIndex(path, "/")
+	if i < 0 {
+		return nil, fmt.Errorf("Vault key %q must be given as <mount>/<key>", path)
+	}
+
+	s := &VaultSigner{
+		address: strings.TrimRight(address, "/"),
+		mount:   path[:i],
+		name:    path[i+1:],
+		token:   os.Getenv("VAULT_TOKEN"),
+	}
+	if s.token == "" {
+		return nil, fmt.Errorf("VAULT_TOKEN is not set")
+	}
+
+	if err := s.readPublicKey(); err != nil {
+		return nil, err
+	}
+
+	log.Debug().Str("key", s.mount+"/"+s.name).Int("version", s.version).Msgf("Using Vault Transit key of type %T", s.pub)
+	return s, nil
+}
+
+// readPublicKey reads the public key of the latest version of the transit key.
+func (s *VaultSigner) readPublicKey() error {
+	var resp struct {
+		Data struct {
+			Type          string                     `json:"type"`
+			LatestVersion int                        `json:"latest_version"`
+			Keys          map[string]json.RawMessage `json:"keys"`
+		} `json:"data"`
+	}
+	if err := s.call(http.MethodGet, "keys/"+s.name, nil, &resp); err != nil {
+		return err
+	}
+
+	s.version = resp.Data.LatestVersion
+	raw, ok := resp.Data.Keys[strconv.Itoa(s.version)]
+	if !ok {
+		return fmt.Errorf("Vault did not return version %d of key %q", s.version, s.name)
+	}
+
+	// every asymmetric key version is an object holding the public key, which is base64 for ed25519
+	// keys and PEM for the other key types
+	var key struct {
+		PublicKey string `json:"public_key"`
+	}
+	if err := json.Unmarshal(raw, &key); err != nil || key.PublicKey == "" {
+		return fmt.Errorf("Vault key %q of type %s has no public key", s.name, resp.Data.Type)
+	}
+
+	if resp.Data.Type == "ed25519" {
+		pub, err := base64.StdEncoding.DecodeString(key.PublicKey)
+		if err != nil || len(pub) != ed25519.PublicKeySize {
+			return fmt.Errorf("invalid Vault ed25519 public key")
+		}
+		s.pub = ed25519.PublicKey(pub)
+		return nil
+	}
+
+	block, _ := pem.Decode([]byte(key.PublicKey))
+	if block == nil {
+		return fmt.Errorf("invalid Vault public key PEM")
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("invalid Vault public key: %w", err)
+	}
+	s.pub = pub
+	return nil
+}
+
+// Public returns the public key of the transit key.
+func (s *VaultSigner) Public() crypto.PublicKey {
+	return s.pub
+}
+
+// Sign signs a digest with the transit key, or the message itself for Ed25519.
+func (s *VaultSigner) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	req := map[string]any{
+		"input":       base64.StdEncoding.EncodeToString(digest),
+		"key_version": s.version,
+	}
+
+	switch s.pub.(type) {
+	case ed25519.PublicKey:
+	case *rsa.PublicKey, *ecdsa.PublicKey:
+		h, ok := vaultHashes[opts.HashFunc()]
+		if !ok {
+			return nil, fmt.Errorf("unsupported hash algorithm %s for Vault signing", opts.HashFunc())
+		}
+		req["prehashed"] = true
+		req["hash_algorithm"] = h
+		req["marshaling_algorithm"] = "asn1"
+		if _, ok := s.pub.(*rsa.PublicKey); ok {
+			req["signature_algorithm"] = "pkcs1v15"
+			if pss, ok := opts.(*rsa.PSSOptions); ok {
+				if pss.SaltLength != rsa.PSSSaltLengthEqualsHash && pss.SaltLength != opts.HashFunc().Size() {
+					return nil, fmt.Errorf("Vault signing only supports PSS salts of the hash length")
+				}
+				req["signature_algorithm"] = "pss"
+				req["salt_length"] = "hash"
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported Vault public key type %T", s.pub)
+	}
+
+	var resp struct {
+		Data struct {
+			Signature string `json:"signature"`
+		} `json:"data"`
+	}
+	if err := s.call(http.MethodPost, "sign/"+s.name, req, &resp); err != nil {
+		return nil, err
+	}
+
+	// signatures are returned as vault:v<version>:<base64>
+	parts := strings.SplitN(resp.Data.Signature, ":", 3)
+	if len(parts) != 3 || parts[0] != "vault" {
+		return nil, fmt.Errorf("unexpected Vault signature format")
+	}
+	signature, err := base64.StdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid Vault signature: %w", err)
+	}
+	return signature, nil
+}
+
+// call sends a request to the transit secrets engine and decodes the JSON response into out.
+func (s *VaultSigner) call(method, path string, body any, out any) error {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal Vault request: %w", err)
+		}
+		reqBody = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, s.address+"/v1/"+s.mount+"/"+path, reqBody)
+	if err != nil {
+		return fmt.Errorf("failed to create Vault request: %w", err)
+	}
+	req.Header.Set("X-Vault-Token", s.token)
+	if namespace := os.Getenv("VAULT_NAMESPACE"); namespace != "" {
+		req.Header.Set("X-Vault-Namespace", namespace)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("Vault request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read Vault response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var vaultErr struct {
+			Errors []string `json:"errors"`
+		}
+		if json.Unmarshal(data, &vaultErr) == nil && len(vaultErr.Errors) > 0 {
+			return fmt.Errorf("Vault returned %s: %s", resp.Status, strings.Join(vaultErr.Errors, "; "))
+		}
+		return fmt.Errorf("Vault returned %s", resp.Status)
+	}
+
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("invalid Vault response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package signer
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// vaultDevServer stands in for the transit secrets engine of a Vault dev mode server (vault server
+// -dev) holding a single key "my_ca" mounted at "transit". Responses follow the shapes Vault returns.
+func vaultDevServer(t *testing.T, keyType string, key crypto.Signer) *httptest.Server {
+	t.Helper()
+
+	var publicKey, name string
+	switch keyType {
+	case "ed25519":
+		name = "ed25519"
+		publicKey = base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey))
+	default:
+		name = map[string]string{"ecdsa-p256": "P-256", "rsa-2048": "rsa-2048"}[keyType]
+		der, err := x509.MarshalPKIXPublicKey(key.Public())
+		if err != nil {
+			t.Fatal(err)
+		}
+		publicKey = string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/transit/keys/my_ca", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{
+			"request_id": "5d7d5d57-8c3e-9c39-9b5e-2d3b5b0fd1a1",
+			"lease_id": "",
+			"renewable": false,
+			"lease_duration": 0,
+			"data": {
+				"allow_plaintext_backup": false,
+				"auto_rotate_period": 0,
+				"deletion_allowed": false,
+				"derived": false,
+				"exportable": false,
+				"imported_key": false,
+				"keys": {
+					"1": {
+						"certificate_chain": "",
+						"creation_time": "2024-05-01T10:00:00.123456789Z",
+						"name": %q,
+						"public_key": %q
+					}
+				},
+				"latest_version": 1,
+				"min_available_version": 0,
+				"min_decryption_version": 1,
+				"min_encryption_version": 0,
+				"name": "my_ca",
+				"supports_decryption": false,
+				"supports_derivation": true,
+				"supports_encryption": false,
+				"supports_signing": true,
+				"type": %q
+			},
+			"wrap_info": null,
+			"warnings": null,
+			"auth": null,
+			"mount_type": "transit"
+		}`, name, publicKey, keyType)
+	})
+	mux.HandleFunc("POST /v1/transit/sign/my_ca", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Input              string `json:"input"`
+			KeyVersion         int    `json:"key_version"`
+			Prehashed          bool   `json:"prehashed"`
+			HashAlgorithm      string `json:"hash_algorithm"`
+			SignatureAlgorithm string `json:"signature_algorithm"`
+			SaltLength         string `json:"salt_length"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.KeyVersion != 1 {
+			http.Error(w, `{"errors": ["invalid request"]}`, http.StatusBadRequest)
+			return
+		}
+		input, _ := base64.StdEncoding.DecodeString(req.Input)
+
+		var opts crypto.SignerOpts = crypto.Hash(0)
+		if keyType != "ed25519" {
+			if !req.Prehashed {
+				http.Error(w, `{"errors": ["expected a prehashed input"]}`, http.StatusBadRequest)
+				return
+			}
+			opts = map[string]crypto.Hash{"sha2-256": crypto.SHA256, "sha2-384": crypto.SHA384, "sha2-512": crypto.SHA512}[req.HashAlgorithm]
+			if req.SignatureAlgorithm == "pss" && req.SaltLength == "hash" {
+				opts = &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: opts.HashFunc()}
+			}
+		}
+
+		signature, err := key.Sign(rand.Reader, input, opts)
+		if err != nil {
+			http.Error(w, `{"errors": ["signing failed"]}`, http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprintf(w, `{"request_id": "0b1c5f0e-2f4a-6d1e-8c1f-3a9d0e4b7c21", "data": {"key_version": 1, "signature": "vault:v1:%s"}}`,
+			base64.StdEncoding.EncodeToString(signature))
+	})
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "root" {
+			http.Error(w, `{"errors": ["permission denied"]}`, http.StatusForbidden)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestVaultSigner(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		keyType string
+		key     crypto.Signer
+		sigAlg  x509.SignatureAlgorithm
+	}{
+		{"ed25519", edKey, x509.PureEd25519},
+		{"ecdsa-p256", ecKey, x509.ECDSAWithSHA256},
+		{"rsa-2048", rsaKey, x509.SHA256WithRSA},
+		{"rsa-2048", rsaKey, x509.SHA256WithRSAPSS},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.keyType+"/"+tt.sigAlg.String(), func(t *testing.T) {
+			srv := vaultDevServer(t, tt.keyType, tt.key)
+			t.Setenv("VAULT_ADDR", srv.URL)
+			t.Setenv("VAULT_TOKEN", "root")
+			t.Setenv("VAULT_NAMESPACE", "")
+
+			s, err := Open("vault:transit/my_ca")
+			if err != nil {
+				t.Fatalf("failed to open Vault signer: %v", err)
+			}
+			if !tt.key.Public().(interface{ Equal(crypto.PublicKey) bool }).Equal(s.Public()) {
+				t.Fatal("public key read from Vault does not match")
+			}
+
+			crt := selfSignedCA(t, s, tt.sigAlg)
+			der, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+				SignatureAlgorithm: tt.sigAlg,
+				Number:             big.NewInt(1),
+				ThisUpdate:         time.Now(),
+				NextUpdate:         time.Now().Add(24 * time.Hour),
+			}, crt, s)
+			if err != nil {
+				t.Fatalf("failed to sign CRL: %v", err)
+			}
+			rl, err := x509.ParseRevocationList(der)
+			if err != nil {
+				t.Fatalf("failed to parse CRL: %v", err)
+			}
+			if err := rl.CheckSignatureFrom(crt); err != nil {
+				t.Fatalf("CRL signature does not verify: %v", err)
+			}
+		})
+	}
+}
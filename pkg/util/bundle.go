@@ -0,0 +1,103 @@
+package util
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ReadCertificates reads the certificates at path, which may be a single certificate, a PEM bundle such
+// as a chain file, a PKCS#12 bundle or a directory of certificate files. For PKCS#12 bundles only the
+// certificate matching the bundle's key is returned.
+func ReadCertificates(path, password string) ([]*x509.Certificate, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificates: %w", err)
+	}
+
+	var crts []*x509.Certificate
+	if info.IsDir() {
+		crts, err = ReadCertificatesDir(path)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read certificates: %w", err)
+		}
+		if IsPKCS12(data) {
+			_, crt, err := ParsePKCS12(data, password)
+			if err != nil {
+				return nil, err
+			}
+			return []*x509.Certificate{crt}, nil
+		}
+		crts = certificatesFromData(path, data)
+	}
+
+	if len(crts) == 0 {
+		return nil, fmt.Errorf("no certificates found in %s", path)
+	}
+	return crts, nil
+}
+
+// ErrNoMatchingCertificate is returned by SelectCertificate when no certificate matches the selector.
+var ErrNoMatchingCertificate = errors.New("no matching certificate")
+
+// CertificateSelector describes the issuer certificate to pick out of a bundle. Empty fields are ignored.
+type CertificateSelector struct {
+	Key        crypto.Signer // the certificate's public key must match this key
+	Subject    string        // full subject DN or common name, compared case-insensitively
+	RawSubject []byte        // DER encoded subject, e.g. the issuer of a TBS CRL
+	SKI        []byte        // subject key identifier
+}
+
+func (sel CertificateSelector) matches(crt *x509.Certificate) bool {
+	if sel.Key != nil && VerifyCrtKeyMatch(crt, sel.Key) != nil {
+		return false
+	}
+	if sel.Subject != "" && !strings.EqualFold(crt.Subject.String(), sel.Subject) &&
+		!strings.EqualFold(crt.Subject.CommonName, sel.Subject) {
+		return false
+	}
+	if sel.RawSubject != nil && !bytes.Equal(crt.RawSubject, sel.RawSubject) {
+		return false
+	}
+	if sel.SKI != nil && !bytes.Equal(crt.SubjectKeyId, sel.SKI) {
+		return false
+	}
+	return true
+}
+
+// SelectCertificate returns the certificate matching sel. Several matches are only accepted when they are
+// interchangeable for signing CRLs, i.e. share subject, subject key identifier and public key, as with a
+// renewed or cross-signed CA certificate; the first one is returned then.
+func SelectCertificate(crts []*x509.Certificate, sel CertificateSelector) (*x509.Certificate, error) {
+	var matches []*x509.Certificate
+	for _, crt := range crts {
+		if sel.matches(crt) {
+			matches = append(matches, crt)
+		}
+	}
+
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("%w among %d certificates", ErrNoMatchingCertificate, len(crts))
+	}
+
+	first := matches[0]
+	for _, crt := range matches[1:] {
+		if !bytes.Equal(crt.RawSubject, first.RawSubject) ||
+			!bytes.Equal(crt.SubjectKeyId, first.SubjectKeyId) ||
+			!bytes.Equal(crt.RawSubjectPublicKeyInfo, first.RawSubjectPublicKeyInfo) {
+			return nil, fmt.Errorf("%d of the %d certificates match (%s and %s)",
+				len(matches), len(crts), first.Subject, crt.Subject)
+		}
+	}
+
+	return first, nil
+}
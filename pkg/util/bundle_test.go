@@ -0,0 +1,152 @@
+package util
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newBundleTestKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// newBundleTestCA issues a CA certificate for key, self-signed when parent is nil.
+func newBundleTestCA(t *testing.T, serial int64, cn string, key crypto.Signer, ski []byte, parent *x509.Certificate, parentKey crypto.Signer) *x509.Certificate {
+	t.Helper()
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: cn},
+		SubjectKeyId:          ski,
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, key.Public(), parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crt, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return crt
+}
+
+func writeBundle(t *testing.T, path string, crts ...*x509.Certificate) {
+	t.Helper()
+	var data []byte
+	for _, crt := range crts {
+		data = append(data, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: crt.Raw})...)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSelectCertificate(t *testing.T) {
+	rootKey := newBundleTestKey(t)
+	issuingKey := newBundleTestKey(t)
+	otherKey := newBundleTestKey(t)
+
+	root := newBundleTestCA(t, 1, "Root CA", rootKey, []byte{0x01}, nil, nil)
+	issuing := newBundleTestCA(t, 2, "Issuing CA", issuingKey, []byte{0x02}, root, rootKey)
+	// renewed is interchangeable with issuing: same subject, key and key identifier
+	renewed := newBundleTestCA(t, 3, "Issuing CA", issuingKey, []byte{0x02}, root, rootKey)
+	// rekeyed has the subject of issuing but another key
+	rekeyed := newBundleTestCA(t, 4, "Issuing CA", otherKey, []byte{0x04}, root, rootKey)
+
+	dir := t.TempDir()
+	chain := filepath.Join(dir, "chain.pem")
+	writeBundle(t, chain, issuing, root)
+	renewals := filepath.Join(dir, "renewals.pem")
+	writeBundle(t, renewals, issuing, renewed)
+	crtDir := filepath.Join(dir, "crts")
+	if err := os.Mkdir(crtDir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	writeBundle(t, filepath.Join(crtDir, "1-root.crt"), root)
+	writeBundle(t, filepath.Join(crtDir, "2-issuing.crt"), issuing)
+	writeBundle(t, filepath.Join(crtDir, "3-rekeyed.crt"), rekeyed)
+
+	tests := []struct {
+		name      string
+		path      string
+		sel       CertificateSelector
+		want      *x509.Certificate
+		noMatch   bool // the error must be ErrNoMatchingCertificate
+		ambiguous bool // the error must report several matches
+	}{
+		{name: "key of the issuing CA in a chain file", path: chain, sel: CertificateSelector{Key: issuingKey}, want: issuing},
+		{name: "key of the root in a chain file", path: chain, sel: CertificateSelector{Key: rootKey}, want: root},
+		{name: "key not in a chain file", path: chain, sel: CertificateSelector{Key: otherKey}, noMatch: true},
+		{name: "key in a directory", path: crtDir, sel: CertificateSelector{Key: otherKey}, want: rekeyed},
+		{name: "subject common name", path: crtDir, sel: CertificateSelector{Subject: "root ca"}, want: root},
+		{name: "subject DN", path: chain, sel: CertificateSelector{Subject: "CN=Issuing CA"}, want: issuing},
+		{name: "unknown subject", path: crtDir, sel: CertificateSelector{Subject: "Other CA"}, noMatch: true},
+		{name: "SKI", path: crtDir, sel: CertificateSelector{SKI: []byte{0x04}}, want: rekeyed},
+		{name: "subject matching a re-keyed CA", path: crtDir, sel: CertificateSelector{Subject: "Issuing CA"}, ambiguous: true},
+		{name: "subject and SKI", path: crtDir, sel: CertificateSelector{Subject: "Issuing CA", SKI: []byte{0x02}}, want: issuing},
+		{name: "TBS issuer matching a re-keyed CA", path: crtDir, sel: CertificateSelector{RawSubject: issuing.RawSubject}, ambiguous: true},
+		{name: "TBS issuer and subject", path: crtDir, sel: CertificateSelector{RawSubject: root.RawSubject, Subject: "Root CA"}, want: root},
+		{name: "TBS issuer and SKI", path: crtDir, sel: CertificateSelector{RawSubject: issuing.RawSubject, SKI: []byte{0x04}}, want: rekeyed},
+		{name: "TBS issuer and mismatching SKI", path: crtDir, sel: CertificateSelector{RawSubject: root.RawSubject, SKI: []byte{0x02}}, noMatch: true},
+		{name: "renewed CA by key", path: renewals, sel: CertificateSelector{Key: issuingKey}, want: issuing},
+		{name: "renewed CA by TBS issuer", path: renewals, sel: CertificateSelector{RawSubject: issuing.RawSubject}, want: issuing},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			crts, err := ReadCertificates(tt.path, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			crt, err := SelectCertificate(crts, tt.sel)
+			switch {
+			case tt.noMatch:
+				if !errors.Is(err, ErrNoMatchingCertificate) {
+					t.Errorf("got %v, want ErrNoMatchingCertificate", err)
+				}
+			case tt.ambiguous:
+				if err == nil || errors.Is(err, ErrNoMatchingCertificate) {
+					t.Errorf("got %v, want an error for several matches", err)
+				}
+			case err != nil:
+				t.Errorf("SelectCertificate failed: %v", err)
+			case !crt.Equal(tt.want):
+				t.Errorf("got %s (serial %s), want serial %s", crt.Subject, crt.SerialNumber, tt.want.SerialNumber)
+			}
+		})
+	}
+}
+
+func TestReadCertificatesPKCS12(t *testing.T) {
+	// only the certificate of the bundle's key is returned, even when the CA is listed first
+	crts, err := ReadCertificates("testdata/pkcs12-ca-first.p12", "revokr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf := mustParseCertificate(t, "testdata/pkcs12-leaf.crt")
+	if len(crts) != 1 || !crts[0].Equal(leaf) {
+		t.Errorf("got %d certificates, want only pkcs12-leaf.crt", len(crts))
+	}
+}
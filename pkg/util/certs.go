@@ -2,14 +2,19 @@ package util
 
 import (
 	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/asn1"
-	"encoding/base64"
 	"fmt"
-	"hash"
+	"strings"
 
 	"github.com/rs/zerolog/log"
+	"golang.org/x/crypto/cryptobyte"
+	cryptobyte_asn1 "golang.org/x/crypto/cryptobyte/asn1"
 )
 
 type RawCRL struct {
@@ -18,37 +23,203 @@ type RawCRL struct {
 	SignatureValue     asn1.BitString
 }
 
-func GetSignatureAlgAndHash(crt *x509.Certificate) (pkix.AlgorithmIdentifier, hash.Hash, error) {
-	switch crt.SignatureAlgorithm {
+// supportedSignatureAlgorithms lists the signature algorithms revokr can sign CRLs with.
+var supportedSignatureAlgorithms = []x509.SignatureAlgorithm{
+	x509.SHA256WithRSA,
+	x509.SHA384WithRSA,
+	x509.SHA512WithRSA,
+	x509.SHA256WithRSAPSS,
+	x509.SHA384WithRSAPSS,
+	x509.SHA512WithRSAPSS,
+	x509.ECDSAWithSHA256,
+	x509.ECDSAWithSHA384,
+	x509.ECDSAWithSHA512,
+	x509.PureEd25519,
+}
+
+// DefaultSignatureAlgorithm picks the signature algorithm for a CA key. The algorithm the issuer
+// certificate itself was signed with belongs to the parent CA and must not be used.
+func DefaultSignatureAlgorithm(pub crypto.PublicKey) (x509.SignatureAlgorithm, error) {
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		return x509.SHA256WithRSA, nil
+	case *ecdsa.PublicKey:
+		switch k.Curve {
+		case elliptic.P256():
+			return x509.ECDSAWithSHA256, nil
+		case elliptic.P384():
+			return x509.ECDSAWithSHA384, nil
+		case elliptic.P521():
+			return x509.ECDSAWithSHA512, nil
+		}
+		return x509.UnknownSignatureAlgorithm, fmt.Errorf("unsupported ECDSA curve: %s", k.Curve.Params().Name)
+	case ed25519.PublicKey:
+		return x509.PureEd25519, nil
+	}
+	return x509.UnknownSignatureAlgorithm, fmt.Errorf("unsupported public key type: %T", pub)
+}
+
+// ParseSignatureAlgorithm parses a signature algorithm name as printed by Go (e.g. "SHA256-RSA" or
+// "ECDSA-SHA384"), case-insensitively.
+func ParseSignatureAlgorithm(name string) (x509.SignatureAlgorithm, error) {
+	var names []string
+	for _, alg := range supportedSignatureAlgorithms {
+		if strings.EqualFold(alg.String(), name) {
+			return alg, nil
+		}
+		names = append(names, alg.String())
+	}
+	return x509.UnknownSignatureAlgorithm, fmt.Errorf("unsupported signature algorithm %q (supported: %s)", name, strings.Join(names, ", "))
+}
+
+// CheckSignatureAlgorithm verifies that the signature algorithm can be used with the public key.
+func CheckSignatureAlgorithm(alg x509.SignatureAlgorithm, pub crypto.PublicKey) error {
+	var keyAlg x509.PublicKeyAlgorithm
+	switch pub.(type) {
+	case *rsa.PublicKey:
+		keyAlg = x509.RSA
+	case *ecdsa.PublicKey:
+		keyAlg = x509.ECDSA
+	case ed25519.PublicKey:
+		keyAlg = x509.Ed25519
+	default:
+		return fmt.Errorf("unsupported public key type: %T", pub)
+	}
+
+	var algKeyAlg x509.PublicKeyAlgorithm
+	switch alg {
+	case x509.SHA256WithRSA, x509.SHA384WithRSA, x509.SHA512WithRSA,
+		x509.SHA256WithRSAPSS, x509.SHA384WithRSAPSS, x509.SHA512WithRSAPSS:
+		algKeyAlg = x509.RSA
+	case x509.ECDSAWithSHA256, x509.ECDSAWithSHA384, x509.ECDSAWithSHA512:
+		algKeyAlg = x509.ECDSA
+	case x509.PureEd25519:
+		algKeyAlg = x509.Ed25519
+	default:
+		return fmt.Errorf("unsupported signature algorithm: %v", alg)
+	}
+
+	if keyAlg != algKeyAlg {
+		return fmt.Errorf("signature algorithm %v cannot be used with an %v key", alg, keyAlg)
+	}
+	return nil
+}
+
+// SignatureAlgorithmForKey returns the named signature algorithm after checking it against the public
+// key, or the default algorithm for the key if name is empty.
+func SignatureAlgorithmForKey(pub crypto.PublicKey, name string) (x509.SignatureAlgorithm, error) {
+	if name == "" {
+		return DefaultSignatureAlgorithm(pub)
+	}
+
+	alg, err := ParseSignatureAlgorithm(name)
+	if err != nil {
+		return x509.UnknownSignatureAlgorithm, err
+	}
+
+	if err := CheckSignatureAlgorithm(alg, pub); err != nil {
+		return x509.UnknownSignatureAlgorithm, err
+	}
+	return alg, nil
+}
+
+// pssParameters is the RSASSA-PSS-params structure from RFC 4055 section 3.1.
+type pssParameters struct {
+	Hash         pkix.AlgorithmIdentifier `asn1:"explicit,tag:0"`
+	MGF          pkix.AlgorithmIdentifier `asn1:"explicit,tag:1"`
+	SaltLength   int                      `asn1:"explicit,tag:2"`
+	TrailerField int                      `asn1:"optional,explicit,tag:3,default:1"`
+}
+
+var hashAlgorithmOIDs = map[crypto.Hash]asn1.ObjectIdentifier{
+	crypto.SHA256: {2, 16, 840, 1, 101, 3, 4, 2, 1}, // id-sha256
+	crypto.SHA384: {2, 16, 840, 1, 101, 3, 4, 2, 2}, // id-sha384
+	crypto.SHA512: {2, 16, 840, 1, 101, 3, 4, 2, 3}, // id-sha512
+}
+
+// rsaPSSAlgorithmIdentifier builds the RSASSA-PSS AlgorithmIdentifier using MGF1 with the same hash and a
+// salt as long as the hash, encoded the same way crypto/x509 does so that inner and outer identifiers match.
+func rsaPSSAlgorithmIdentifier(h crypto.Hash) pkix.AlgorithmIdentifier {
+	hashAlg := pkix.AlgorithmIdentifier{
+		Algorithm:  hashAlgorithmOIDs[h],
+		Parameters: asn1.RawValue{Tag: 5}, // NULL
+	}
+	// a hash AlgorithmIdentifier and the params of fixed size cannot fail to marshal
+	mgfParams, _ := asn1.Marshal(hashAlg)
+	params, _ := asn1.Marshal(pssParameters{
+		Hash: hashAlg,
+		MGF: pkix.AlgorithmIdentifier{
+			Algorithm:  asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 8}, // id-mgf1
+			Parameters: asn1.RawValue{FullBytes: mgfParams},
+		},
+		SaltLength:   h.Size(),
+		TrailerField: 1,
+	})
+	return pkix.AlgorithmIdentifier{
+		Algorithm:  asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 10}, // id-RSASSA-PSS
+		Parameters: asn1.RawValue{FullBytes: params},
+	}
+}
+
+// SignaturePadding describes the padding an external signer must apply for the signature algorithm.
+func SignaturePadding(alg x509.SignatureAlgorithm) string {
+	switch alg {
+	case x509.SHA256WithRSA, x509.SHA384WithRSA, x509.SHA512WithRSA:
+		return "PKCS#1 v1.5"
+	case x509.SHA256WithRSAPSS:
+		return "RSASSA-PSS (MGF1 with SHA-256, salt length 32)"
+	case x509.SHA384WithRSAPSS:
+		return "RSASSA-PSS (MGF1 with SHA-384, salt length 48)"
+	case x509.SHA512WithRSAPSS:
+		return "RSASSA-PSS (MGF1 with SHA-512, salt length 64)"
+	default:
+		return "none"
+	}
+}
+
+// GetSignatureAlgAndHash returns the AlgorithmIdentifier and a hash function for the signature algorithm.
+// The hash is zero for Ed25519, which signs the message itself rather than a prehash.
+func GetSignatureAlgAndHash(alg x509.SignatureAlgorithm) (pkix.AlgorithmIdentifier, crypto.Hash, error) {
+	switch alg {
 	case x509.SHA256WithRSA:
 		return pkix.AlgorithmIdentifier{
 			Algorithm:  asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 11}, // sha256WithRSAEncryption
 			Parameters: asn1.RawValue{Tag: 5},                              // NULL
-		}, crypto.SHA256.New(), nil
+		}, crypto.SHA256, nil
 	case x509.ECDSAWithSHA256:
 		return pkix.AlgorithmIdentifier{
 			Algorithm: asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}, // ecdsa-with-SHA256
-		}, crypto.SHA256.New(), nil
+		}, crypto.SHA256, nil
 	case x509.SHA384WithRSA:
 		return pkix.AlgorithmIdentifier{
 			Algorithm:  asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 12}, // sha384WithRSAEncryption
 			Parameters: asn1.RawValue{Tag: 5},                              // NULL
-		}, crypto.SHA384.New(), nil
+		}, crypto.SHA384, nil
 	case x509.ECDSAWithSHA384:
 		return pkix.AlgorithmIdentifier{
 			Algorithm: asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 3}, // ecdsa-with-SHA384
-		}, crypto.SHA384.New(), nil
+		}, crypto.SHA384, nil
 	case x509.SHA512WithRSA:
 		return pkix.AlgorithmIdentifier{
 			Algorithm:  asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 13}, // sha512WithRSAEncryption
 			Parameters: asn1.RawValue{Tag: 5},                              // NULL
-		}, crypto.SHA512.New(), nil
+		}, crypto.SHA512, nil
 	case x509.ECDSAWithSHA512:
 		return pkix.AlgorithmIdentifier{
 			Algorithm: asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 4}, // ecdsa-with-SHA512
-		}, crypto.SHA512.New(), nil
+		}, crypto.SHA512, nil
+	case x509.SHA256WithRSAPSS:
+		return rsaPSSAlgorithmIdentifier(crypto.SHA256), crypto.SHA256, nil
+	case x509.SHA384WithRSAPSS:
+		return rsaPSSAlgorithmIdentifier(crypto.SHA384), crypto.SHA384, nil
+	case x509.SHA512WithRSAPSS:
+		return rsaPSSAlgorithmIdentifier(crypto.SHA512), crypto.SHA512, nil
+	case x509.PureEd25519:
+		return pkix.AlgorithmIdentifier{
+			Algorithm: asn1.ObjectIdentifier{1, 3, 101, 112}, // id-Ed25519, parameters MUST be absent
+		}, 0, nil
 	default:
-		return pkix.AlgorithmIdentifier{}, nil, fmt.Errorf("unsupported signature algorithm: %v", crt.SignatureAlgorithm)
+		return pkix.AlgorithmIdentifier{}, 0, fmt.Errorf("unsupported signature algorithm: %v", alg)
 	}
 }
 
@@ -80,21 +251,52 @@ func ExtractTBS(crl []byte) ([]byte, error) {
 	return certList.TBS.FullBytes, nil
 }
 
-func ReadSignatureFile(path string) ([]byte, error) {
-	// Read and parse the signature file
-	block, err := TryParsePEM(path)
+// TBSSignatureAlgorithm returns the DER encoded signature AlgorithmIdentifier inside a TBS CRL.
+func TBSSignatureAlgorithm(tbs []byte) ([]byte, error) {
+	input := cryptobyte.String(tbs)
+
+	var seq cryptobyte.String
+	if !input.ReadASN1(&seq, cryptobyte_asn1.SEQUENCE) {
+		return nil, fmt.Errorf("malformed TBS CRL")
+	}
+
+	// the version is optional and only present in v2 CRLs
+	if seq.PeekASN1Tag(cryptobyte_asn1.INTEGER) && !seq.SkipASN1(cryptobyte_asn1.INTEGER) {
+		return nil, fmt.Errorf("malformed TBS CRL version")
+	}
+
+	var ai cryptobyte.String
+	if !seq.ReadASN1Element(&ai, cryptobyte_asn1.SEQUENCE) {
+		return nil, fmt.Errorf("malformed TBS CRL signature algorithm")
+	}
+
+	return ai, nil
+}
+
+// ParseTBS parses a TBS CRL into a RevocationList without a signature, so its contents can be inspected
+// before it is signed. The signature related fields of the result are meaningless.
+func ParseTBS(tbs []byte) (*x509.RevocationList, error) {
+	ai, err := TBSSignatureAlgorithm(tbs)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read signature file: %w", err)
+		return nil, err
 	}
 
-	// check if the data is in base64
-	var b64buf = make([]byte, base64.StdEncoding.EncodedLen(len(block.Bytes)))
-	n, err := base64.StdEncoding.Decode(b64buf, block.Bytes)
-	if err == nil {
-		log.Debug().Msgf("Decoded base64 signature data from file %q", path)
-		return b64buf[:n], nil
+	// wrap the TBS with its own signature algorithm and an empty signature
+	crl, err := asn1.Marshal(struct {
+		TBS                asn1.RawValue
+		SignatureAlgorithm asn1.RawValue
+		SignatureValue     asn1.BitString
+	}{
+		TBS:                asn1.RawValue{FullBytes: tbs},
+		SignatureAlgorithm: asn1.RawValue{FullBytes: ai},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to wrap TBS CRL: %w", err)
 	}
 
-	log.Debug().Msgf("Read raw signature data from file %q", path)
-	return block.Bytes, nil
+	rl, err := x509.ParseRevocationList(crl)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse TBS CRL: %w", err)
+	}
+	return rl, nil
 }
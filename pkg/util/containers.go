@@ -0,0 +1,108 @@
+package util
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+
+	"golang.org/x/crypto/cryptobyte"
+	cryptobyte_asn1 "golang.org/x/crypto/cryptobyte/asn1"
+	"golang.org/x/crypto/ssh"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+var (
+	oidPKCS7Data       = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidPKCS7SignedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+)
+
+// IsPKCS12 reports whether data looks like a DER encoded PKCS#12 PFX (RFC 7292 section 4).
+func IsPKCS12(data []byte) bool {
+	input := cryptobyte.String(data)
+
+	var pfx, authSafe cryptobyte.String
+	var version int
+	var contentType asn1.ObjectIdentifier
+	if !input.ReadASN1(&pfx, cryptobyte_asn1.SEQUENCE) ||
+		!pfx.ReadASN1Integer(&version) || version != 3 ||
+		!pfx.ReadASN1(&authSafe, cryptobyte_asn1.SEQUENCE) ||
+		!authSafe.ReadASN1ObjectIdentifier(&contentType) {
+		return false
+	}
+
+	return contentType.Equal(oidPKCS7Data) || contentType.Equal(oidPKCS7SignedData)
+}
+
+// IsPKCS12File reports whether the file at path is a PKCS#12 bundle.
+func IsPKCS12File(path string) bool {
+	data, err := os.ReadFile(path)
+	return err == nil && IsPKCS12(data)
+}
+
+// ParsePKCS12 decodes a PKCS#12 bundle and returns its private key together with the certificate in the
+// bundle that matches the key. Bundles often carry the CA chain as well, in no particular order.
+func ParsePKCS12(data []byte, password string) (crypto.Signer, *x509.Certificate, error) {
+	priv, crt, caCrts, err := pkcs12.DecodeChain(data, password)
+	if err != nil {
+		if errors.Is(err, pkcs12.ErrIncorrectPassword) && password == "" {
+			return nil, nil, fmt.Errorf("PKCS#12 bundle is encrypted but no password was provided")
+		}
+		return nil, nil, fmt.Errorf("failed to decode PKCS#12 bundle: %w", err)
+	}
+
+	// go-pkcs12 returns ed25519 keys by value, other keys as pointers
+	key, ok := priv.(crypto.Signer)
+	if !ok {
+		return nil, nil, fmt.Errorf("PKCS#12 private key of type %T is not supported", priv)
+	}
+
+	for _, candidate := range append([]*x509.Certificate{crt}, caCrts...) {
+		if VerifyCrtKeyMatch(candidate, key) == nil {
+			return key, candidate, nil
+		}
+	}
+
+	return nil, nil, fmt.Errorf("PKCS#12 bundle does not contain a certificate matching its private key")
+}
+
+// parseOpenSSHPrivateKey parses an "OPENSSH PRIVATE KEY" block holding an Ed25519, ECDSA or RSA key.
+func parseOpenSSHPrivateKey(block *pem.Block, password string) (crypto.Signer, error) {
+	data := pem.EncodeToMemory(block)
+
+	var priv any
+	var err error
+	if password != "" {
+		priv, err = ssh.ParseRawPrivateKeyWithPassphrase(data, []byte(password))
+	} else {
+		priv, err = ssh.ParseRawPrivateKey(data)
+	}
+	if err != nil {
+		var missing *ssh.PassphraseMissingError
+		if errors.As(err, &missing) {
+			return nil, fmt.Errorf("issuer private key is encrypted but no password was provided")
+		}
+		return nil, fmt.Errorf("failed to parse OpenSSH private key: %w", err)
+	}
+
+	// the ssh package returns ed25519 keys as pointers
+	if key, ok := priv.(*ed25519.PrivateKey); ok {
+		priv = *key
+	}
+
+	key, ok := priv.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("OpenSSH private key of type %T is not supported", priv)
+	}
+	return key, nil
+}
+
+// isJWK reports whether data looks like a JSON object rather than DER or PEM.
+func isJWK(data []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(data), []byte("{"))
+}
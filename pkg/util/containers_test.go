@@ -0,0 +1,168 @@
+package util
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// The container-*.pem fixtures are unencrypted PKCS#8 keys written by "openssl genpkey". The other
+// fixtures hold the same keys, or the keys of the openssh-* fixtures, in other containers:
+//
+//	jwk-*.json               the container-*.pem keys as JSON Web Keys
+//	pkcs12-leaf-first.p12    openssl pkcs12 -export -inkey container-ec.pem -in pkcs12-leaf.crt -certfile pkcs12-ca.crt -passout pass:revokr
+//	pkcs12-ca-first.p12      the same bundle written by go-pkcs12 with pkcs12-ca.crt in the first certificate bag
+//	openssh-<type>           ssh-keygen -t <type> -N ""
+//	openssh-<type>-enc       the same key, encrypted with ssh-keygen -p -N revokr
+
+func TestParsePrivateSignerContainers(t *testing.T) {
+	tests := []struct {
+		file     string
+		password string
+		want     string // fixture holding the same key
+	}{
+		{"jwk-rsa.json", "", "container-rsa.pem"},
+		{"jwk-ec.json", "", "container-ec.pem"},
+		{"jwk-ed25519.json", "", "container-ed25519.pem"},
+		{"pkcs12-leaf-first.p12", "revokr", "container-ec.pem"},
+		{"pkcs12-ca-first.p12", "revokr", "container-ec.pem"},
+		{"openssh-ed25519-enc", "revokr", "openssh-ed25519"},
+		{"openssh-ecdsa-enc", "revokr", "openssh-ecdsa"},
+		{"openssh-rsa-enc", "revokr", "openssh-rsa"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			want, err := ParsePrivateSigner(filepath.Join("testdata", tt.want), "")
+			if err != nil {
+				t.Fatalf("failed to parse %s: %v", tt.want, err)
+			}
+			key, err := ParsePrivateSigner(filepath.Join("testdata", tt.file), tt.password)
+			if err != nil {
+				t.Fatalf("failed to parse: %v", err)
+			}
+			if !want.(privateKey).Equal(key) {
+				t.Errorf("key does not match %s", tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePrivateSignerEncryptedContainers(t *testing.T) {
+	for _, file := range []string{"pkcs12-leaf-first.p12", "openssh-ed25519-enc", "openssh-ecdsa-enc", "openssh-rsa-enc"} {
+		t.Run(file, func(t *testing.T) {
+			path := filepath.Join("testdata", file)
+			if _, err := ParsePrivateSigner(path, ""); err == nil || !strings.Contains(err.Error(), "no password was provided") {
+				t.Errorf("missing password: got %v, want a missing password error", err)
+			}
+			if _, err := ParsePrivateSigner(path, "wrong"); err == nil {
+				t.Error("wrong password: got no error")
+			}
+		})
+	}
+}
+
+func TestParsePKCS12(t *testing.T) {
+	leaf, err := ParseCertificate("testdata/pkcs12-leaf.crt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the certificate matching the key is returned wherever it is in the bundle
+	for _, file := range []string{"pkcs12-leaf-first.p12", "pkcs12-ca-first.p12"} {
+		t.Run(file, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !IsPKCS12(data) {
+				t.Fatal("fixture is not detected as PKCS#12")
+			}
+			_, crt, err := ParsePKCS12(data, "revokr")
+			if err != nil {
+				t.Fatalf("ParsePKCS12 failed: %v", err)
+			}
+			if !crt.Equal(leaf) {
+				t.Errorf("got certificate %s, want %s", crt.Subject, leaf.Subject)
+			}
+
+			if _, _, err := ParsePKCS12(data, ""); err == nil || !strings.Contains(err.Error(), "no password was provided") {
+				t.Errorf("missing password: got %v, want a missing password error", err)
+			}
+		})
+	}
+
+	for _, file := range []string{"pkcs12-leaf.crt", "container-ec.pem", "jwk-ec.json"} {
+		if IsPKCS12File(filepath.Join("testdata", file)) {
+			t.Errorf("%s is detected as PKCS#12", file)
+		}
+	}
+}
+
+func TestParseJWKInvalid(t *testing.T) {
+	fixture := func(file string) map[string]string {
+		data, err := os.ReadFile(filepath.Join("testdata", file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var jwk map[string]string
+		if err := json.Unmarshal(data, &jwk); err != nil {
+			t.Fatal(err)
+		}
+		return jwk
+	}
+	ec, rsaJWK, ed := fixture("jwk-ec.json"), fixture("jwk-rsa.json"), fixture("jwk-ed25519.json")
+
+	tests := []struct {
+		name    string
+		jwk     map[string]string
+		change  map[string]string // members to set, an empty value removes the member
+		errText string
+	}{
+		{"EC x and y swapped", ec, map[string]string{"x": ec["y"], "y": ec["x"]}, "does not match its private key"},
+		{"EC public key of another key", ec, map[string]string{"y": ec["x"]}, "does not match its private key"},
+		{"EC d with padding", ec, map[string]string{"d": ec["d"] + "="}, "invalid base64url"},
+		{"EC d in standard base64", ec, map[string]string{"d": strings.NewReplacer("-", "+", "_", "/").Replace(ec["d"]) + "+/"}, "invalid base64url"},
+		{"EC x not base64url", ec, map[string]string{"x": "not base64url!"}, "does not match its private key"},
+		{"EC d too short", ec, map[string]string{"d": ec["d"][:40]}, "private key length"},
+		{"EC unsupported curve", ec, map[string]string{"crv": "secp256k1"}, "unsupported JWK curve"},
+		{"EC public key only", ec, map[string]string{"d": ""}, "not a private key"},
+		{"RSA without primes", rsaJWK, map[string]string{"p": "", "q": ""}, "prime factors"},
+		{"RSA d of another key", rsaJWK, map[string]string{"d": rsaJWK["q"]}, "invalid RSA JWK"},
+		{"RSA n not base64url", rsaJWK, map[string]string{"n": "AQAB="}, "invalid base64url"},
+		{"Ed25519 x of another key", ed, map[string]string{"x": ec["x"]}, "does not match its private key"},
+		{"Ed25519 d not base64url", ed, map[string]string{"d": "%%%"}, "invalid base64url"},
+		{"X25519", ed, map[string]string{"crv": "X25519"}, "unsupported JWK curve"},
+		{"symmetric key", map[string]string{"kty": "oct", "k": "AQAB"}, map[string]string{"d": "AQAB"}, "unsupported JWK key type"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jwk := make(map[string]string)
+			for k, v := range tt.jwk {
+				jwk[k] = v
+			}
+			for k, v := range tt.change {
+				if v == "" {
+					delete(jwk, k)
+				} else {
+					jwk[k] = v
+				}
+			}
+			data, err := json.Marshal(jwk)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := parseJWK(data); err == nil || !strings.Contains(err.Error(), tt.errText) {
+				t.Errorf("parseJWK: got %v, want an error containing %q", err, tt.errText)
+			}
+		})
+	}
+
+	if _, err := parseJWK([]byte(`{"kty": "EC",`)); err == nil {
+		t.Error("parseJWK of truncated JSON succeeded")
+	}
+}
@@ -0,0 +1,133 @@
+package util
+
+import (
+	"crypto"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// DigestFormat selects how the digest of a TBS CRL is handed to an external signer.
+type DigestFormat string
+
+const (
+	DigestFormatRaw        DigestFormat = "raw"        // binary digest
+	DigestFormatHex        DigestFormat = "hex"        // hex encoded digest
+	DigestFormatBase64     DigestFormat = "base64"     // base64 encoded digest
+	DigestFormatDigestInfo DigestFormat = "digestinfo" // DER encoded PKCS#1 DigestInfo, for RSA PKCS#1 v1.5 signers
+)
+
+var digestFormats = []DigestFormat{DigestFormatRaw, DigestFormatHex, DigestFormatBase64, DigestFormatDigestInfo}
+
+// ParseDigestFormat parses a digest format name, case-insensitively.
+func ParseDigestFormat(name string) (DigestFormat, error) {
+	var names []string
+	for _, format := range digestFormats {
+		if strings.EqualFold(string(format), name) {
+			return format, nil
+		}
+		names = append(names, string(format))
+	}
+	return "", fmt.Errorf("unsupported digest format %q (supported: %s)", name, strings.Join(names, ", "))
+}
+
+// digestInfo is the DigestInfo structure from RFC 8017 section 9.2.
+type digestInfo struct {
+	DigestAlgorithm pkix.AlgorithmIdentifier
+	Digest          []byte
+}
+
+// MarshalDigestInfo returns the DER encoded PKCS#1 DigestInfo of a digest, which is what an RSA
+// PKCS#1 v1.5 signature signs.
+func MarshalDigestInfo(h crypto.Hash, digest []byte) ([]byte, error) {
+	oid, ok := hashAlgorithmOIDs[h]
+	if !ok {
+		return nil, fmt.Errorf("unsupported hash algorithm %s", h)
+	}
+
+	info, err := asn1.Marshal(digestInfo{
+		DigestAlgorithm: pkix.AlgorithmIdentifier{
+			Algorithm:  oid,
+			Parameters: asn1.RawValue{Tag: 5}, // NULL
+		},
+		Digest: digest,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal DigestInfo: %w", err)
+	}
+	return info, nil
+}
+
+// DigestDescription tells an external signer how to sign a digest written by WriteDigest.
+type DigestDescription struct {
+	SignatureAlgorithm string `json:"signature_algorithm"`
+	Hash               string `json:"hash"`    // "none" when the full TBS CRL has to be signed
+	Padding            string `json:"padding"` // "none" for ECDSA and Ed25519
+	Format             string `json:"format"`
+}
+
+// TBSDigest returns what an external signer has to sign for the TBS CRL, encoded in the given format,
+// together with a description of the signing parameters. Ed25519 signs the message itself, so its
+// "digest" is the full TBS CRL.
+func TBSDigest(tbs []byte, alg x509.SignatureAlgorithm, format DigestFormat) ([]byte, *DigestDescription, error) {
+	_, h, err := GetSignatureAlgAndHash(alg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	desc := &DigestDescription{
+		SignatureAlgorithm: alg.String(),
+		Hash:               "none",
+		Padding:            SignaturePadding(alg),
+		Format:             string(format),
+	}
+
+	digest := tbs
+	if h != 0 {
+		hasher := h.New()
+		hasher.Write(tbs)
+		digest = hasher.Sum(nil)
+		desc.Hash = h.String()
+	}
+
+	switch format {
+	case DigestFormatRaw:
+		return digest, desc, nil
+	case DigestFormatHex:
+		return []byte(hex.EncodeToString(digest)), desc, nil
+	case DigestFormatBase64:
+		return []byte(base64.StdEncoding.EncodeToString(digest)), desc, nil
+	case DigestFormatDigestInfo:
+		if alg != x509.SHA256WithRSA && alg != x509.SHA384WithRSA && alg != x509.SHA512WithRSA {
+			return nil, nil, fmt.Errorf("digest format %s can only be used with RSA PKCS#1 v1.5 signatures", format)
+		}
+		info, err := MarshalDigestInfo(h, digest)
+		if err != nil {
+			return nil, nil, err
+		}
+		return info, desc, nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported digest format %q", format)
+	}
+}
+
+// WriteDigestDescription writes the description of a digest as JSON next to the digest file.
+func WriteDigestDescription(digestPath string, desc *DigestDescription) error {
+	data, err := json.MarshalIndent(desc, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal digest description: %w", err)
+	}
+
+	err = os.WriteFile(digestPath+".json", append(data, '\n'), 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write digest description: %w", err)
+	}
+
+	return nil
+}
@@ -25,7 +25,7 @@ func DummySigner(pub crypto.PublicKey) (crypto.Signer, error) {
 			return nil, err
 		}
 		return priv, nil
-	case *ed25519.PublicKey:
+	case ed25519.PublicKey:
 		_, priv, err := ed25519.GenerateKey(rand.Reader)
 		if err != nil {
 			return nil, err
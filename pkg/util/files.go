@@ -4,10 +4,11 @@ import (
 	"crypto"
 	"crypto/x509"
 	"encoding/asn1"
-	"encoding/base64"
 	"encoding/pem"
 	"fmt"
+	"io/fs"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/rs/zerolog/log"
@@ -35,12 +36,26 @@ func TryParsePEM(path string) (*pem.Block, error) {
 
 // ParseCertificate reads and parses an x509 certificate from the given file path.
 func ParseCertificate(path string) (*x509.Certificate, error) {
+	return ParseCertificateWithPassword(path, "")
+}
+
+// ParseCertificateWithPassword reads and parses an x509 certificate from the given file path. PKCS#12
+// bundles are decrypted with the password and the certificate matching the bundle's key is returned.
+func ParseCertificateWithPassword(path, password string) (*x509.Certificate, error) {
 	// Read and parse the issuer certificate
 	block, err := TryParsePEM(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read issuer certificate: %w", err)
 	}
 
+	if block.Type == "" && IsPKCS12(block.Bytes) {
+		_, crt, err := ParsePKCS12(block.Bytes, password)
+		if err != nil {
+			return nil, err
+		}
+		return crt, nil
+	}
+
 	crt, err := x509.ParseCertificate(block.Bytes)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse issuer certificate: %w", err)
@@ -49,6 +64,70 @@ func ParseCertificate(path string) (*x509.Certificate, error) {
 	return crt, nil
 }
 
+// ReadCertificatesDir reads every certificate found in the files of a directory (recursively). Files may
+// contain one or more PEM certificates or a single DER certificate; files without certificates are skipped.
+func ReadCertificatesDir(dir string) ([]*x509.Certificate, error) {
+	var crts []*x509.Certificate
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read file: %w", err)
+		}
+
+		found := certificatesFromData(path, data)
+		if len(found) == 0 {
+			log.Debug().Str("path", path).Msg("no certificates found in file, skipping")
+		}
+		crts = append(crts, found...)
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificates directory: %w", err)
+	}
+
+	log.Debug().Msgf("Read %d certificates from directory %q", len(crts), dir)
+	return crts, nil
+}
+
+// certificatesFromData returns the PEM certificates in data or, if there are none, data parsed as a single
+// DER certificate. Certificates that fail to parse are skipped with a warning.
+func certificatesFromData(path string, data []byte) []*x509.Certificate {
+	var crts []*x509.Certificate
+	for rest := data; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		crt, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			log.Warn().Err(err).Str("path", path).Msg("failed to parse certificate, skipping")
+			continue
+		}
+		crts = append(crts, crt)
+	}
+
+	if len(crts) == 0 {
+		if crt, err := x509.ParseCertificate(data); err == nil {
+			crts = append(crts, crt)
+		}
+	}
+
+	return crts
+}
+
 // isLegacyEncryptedPEMBlock checks if a PEM block is encrypted using the legacy PEM encryption format.
 func isLegacyEncryptedPEMBlock(block *pem.Block) bool {
 	if block == nil {
@@ -83,13 +162,23 @@ func ParsePrivateSigner(path, password string) (crypto.Signer, error) {
 		return nil, fmt.Errorf("failed to read issuer private key: %w", err)
 	}
 
+	switch {
+	case block.Type == "" && IsPKCS12(block.Bytes):
+		key, _, err := ParsePKCS12(block.Bytes, password)
+		return key, err
+	case block.Type == "" && isJWK(block.Bytes):
+		return parseJWK(block.Bytes)
+	case block.Type == "OPENSSH PRIVATE KEY":
+		return parseOpenSSHPrivateKey(block, password)
+	}
+
 	var (
 		der  []byte            = block.Bytes
 		priv crypto.PrivateKey = nil
 	)
 
 	if isLegacyEncryptedPEMBlock(block) {
-		log.Warn().Msg("legacy PEM encryption detected; consider using PKCS8 format for better security")
+		log.Warn().Msg("legacy PEM encryption detected; consider converting the key with 'revokr key rewrap'")
 		if password == "" {
 			return nil, fmt.Errorf("issuer private key is encrypted but no password was provided")
 		}
@@ -100,7 +189,18 @@ func ParsePrivateSigner(path, password string) (crypto.Signer, error) {
 		}
 	}
 
-	if block.Type != "" && strings.Contains(block.Type, "ENCRYPTED") {
+	if isLegacyPKCS8(der) {
+		log.Warn().Msg("legacy PKCS#8 encryption detected; consider converting the key with 'revokr key rewrap'")
+		if password == "" {
+			return nil, fmt.Errorf("issuer private key is encrypted but no password was provided")
+		}
+
+		p, err := decryptLegacyPKCS8(der, password)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt issuer private key: %w", err)
+		}
+		priv = p
+	} else if block.Type != "" && strings.Contains(block.Type, "ENCRYPTED") {
 		if password == "" {
 			return nil, fmt.Errorf("issuer private key is encrypted but no password was provided")
 		}
@@ -157,17 +257,16 @@ func ParsePrivateSigner(path, password string) (crypto.Signer, error) {
 	return key, nil
 }
 
-func WriteDigest(path string, crl []byte) error {
-	outStr := base64.StdEncoding.EncodeToString(crl)
-
+// WriteDigest writes an encoded digest to the given path, or to stdout if no path is given.
+func WriteDigest(path string, digest []byte) error {
 	if path == "" {
-		fmt.Println(outStr)
+		fmt.Println(string(digest))
 		return nil
 	}
 
-	err := os.WriteFile(path, []byte(outStr), 0644)
+	err := os.WriteFile(path, digest, 0644)
 	if err != nil {
-		return fmt.Errorf("failed to write CRL to file: %w", err)
+		return fmt.Errorf("failed to write digest to file: %w", err)
 	}
 
 	return nil
@@ -0,0 +1,127 @@
+package util
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+)
+
+// jwk holds the private key members of a JSON Web Key (RFC 7517, RFC 7518 section 6, RFC 8037).
+type jwk struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+
+	// RSA
+	N  string `json:"n"`
+	E  string `json:"e"`
+	D  string `json:"d"`
+	P  string `json:"p"`
+	Q  string `json:"q"`
+	Dp string `json:"dp"`
+	Dq string `json:"dq"`
+	Qi string `json:"qi"`
+
+	// EC and OKP
+	X string `json:"x"`
+	Y string `json:"y"`
+}
+
+var jwkCurves = map[string]elliptic.Curve{
+	"P-256": elliptic.P256(),
+	"P-384": elliptic.P384(),
+	"P-521": elliptic.P521(),
+}
+
+// parseJWK parses an RSA, EC or Ed25519 private key in JSON Web Key format.
+func parseJWK(data []byte) (crypto.Signer, error) {
+	var key jwk
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if err := dec.Decode(&key); err != nil {
+		return nil, fmt.Errorf("failed to parse JWK: %w", err)
+	}
+	if key.D == "" {
+		return nil, fmt.Errorf("JWK is not a private key")
+	}
+
+	var err error
+	b64 := func(s string) []byte {
+		v, e := base64.RawURLEncoding.DecodeString(s)
+		if e != nil && err == nil {
+			err = fmt.Errorf("invalid base64url value in JWK: %w", e)
+		}
+		return v
+	}
+	num := func(s string) *big.Int { return new(big.Int).SetBytes(b64(s)) }
+
+	switch key.Kty {
+	case "RSA":
+		if key.P == "" || key.Q == "" {
+			return nil, fmt.Errorf("RSA JWK without the prime factors p and q is not supported")
+		}
+		priv := &rsa.PrivateKey{
+			PublicKey: rsa.PublicKey{N: num(key.N), E: int(num(key.E).Int64())},
+			D:         num(key.D),
+			Primes:    []*big.Int{num(key.P), num(key.Q)},
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := priv.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid RSA JWK: %w", err)
+		}
+		priv.Precompute()
+		return priv, nil
+
+	case "EC":
+		curve, ok := jwkCurves[key.Crv]
+		if !ok {
+			return nil, fmt.Errorf("unsupported JWK curve %q", key.Crv)
+		}
+		size := (curve.Params().BitSize + 7) / 8
+		d := b64(key.D)
+		if err != nil {
+			return nil, err
+		}
+		if len(d) != size {
+			return nil, fmt.Errorf("invalid EC JWK private key length")
+		}
+		priv, parseErr := ecdsa.ParseRawPrivateKey(curve, d)
+		if parseErr != nil {
+			return nil, fmt.Errorf("invalid EC JWK: %w", parseErr)
+		}
+		// the public key is derived from d, reject a JWK whose x and y do not match it
+		point := append([]byte{4}, append(b64(key.X), b64(key.Y)...)...)
+		pub, pubErr := priv.PublicKey.Bytes()
+		if err != nil || pubErr != nil || !bytes.Equal(point, pub) {
+			return nil, fmt.Errorf("EC JWK public key does not match its private key")
+		}
+		return priv, nil
+
+	case "OKP":
+		if key.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported JWK curve %q", key.Crv)
+		}
+		seed := b64(key.D)
+		if err != nil {
+			return nil, err
+		}
+		if len(seed) != ed25519.SeedSize {
+			return nil, fmt.Errorf("invalid Ed25519 JWK private key length")
+		}
+		priv := ed25519.NewKeyFromSeed(seed)
+		if key.X != "" && !bytes.Equal(b64(key.X), priv.Public().(ed25519.PublicKey)) {
+			return nil, fmt.Errorf("Ed25519 JWK public key does not match its private key")
+		}
+		return priv, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported JWK key type %q", key.Kty)
+	}
+}
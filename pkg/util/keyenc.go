@@ -0,0 +1,323 @@
+package util
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"fmt"
+	"hash"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/youmark/pkcs8"
+)
+
+var (
+	oidPBEWithMD5AndDESCBC     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 3}
+	oidPBEWithSHA1AndDESCBC    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 10}
+	oidPBEWithSHAAnd3DESCBC    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 1, 3}
+	oidPBES2                   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2                  = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidScrypt                  = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11591, 4, 11}
+	oidHMACWithSHA256          = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidDESEDE3CBC              = asn1.ObjectIdentifier{1, 2, 840, 113549, 3, 7}
+	oidAES256CBC               = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+	oidAES256GCM               = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 46}
+	pkcs8EncryptionSchemeNames = map[string]string{
+		oidPBEWithMD5AndDESCBC.String():  "PBES1 MD5 and DES-CBC",
+		oidPBEWithSHA1AndDESCBC.String(): "PBES1 SHA-1 and DES-CBC",
+		oidPBEWithSHAAnd3DESCBC.String(): "PKCS#12 PBE SHA-1 and 3DES-CBC",
+		oidDESEDE3CBC.String():           "3DES-CBC",
+		oidAES256CBC.String():            "AES-256-CBC",
+		oidAES256GCM.String():            "AES-256-GCM",
+		"2.16.840.1.101.3.4.1.2":         "AES-128-CBC",
+		"2.16.840.1.101.3.4.1.6":         "AES-128-GCM",
+		"2.16.840.1.101.3.4.1.22":        "AES-192-CBC",
+		"2.16.840.1.101.3.4.1.26":        "AES-192-GCM",
+	}
+)
+
+// encryptedPrivateKeyInfo is the EncryptedPrivateKeyInfo structure of RFC 5208 section 6.
+type encryptedPrivateKeyInfo struct {
+	EncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedData       []byte
+}
+
+// pbeParameter holds the parameters of the PBES1 (RFC 8018 appendix A.3) and PKCS#12 (RFC 7292
+// appendix C) password based encryption schemes.
+type pbeParameter struct {
+	Salt       []byte
+	Iterations int
+}
+
+// isLegacyPKCS8 reports whether der is an encrypted PKCS#8 key using PBES1 or PKCS#12 password based
+// encryption, which the pkcs8 package does not support.
+func isLegacyPKCS8(der []byte) bool {
+	var info encryptedPrivateKeyInfo
+	if _, err := asn1.Unmarshal(der, &info); err != nil {
+		return false
+	}
+	alg := info.EncryptionAlgorithm.Algorithm
+	return alg.Equal(oidPBEWithMD5AndDESCBC) || alg.Equal(oidPBEWithSHA1AndDESCBC) || alg.Equal(oidPBEWithSHAAnd3DESCBC)
+}
+
+// decryptLegacyPKCS8 decrypts a PBES1 or PKCS#12 PBE encrypted PKCS#8 key as written by older OpenSSL
+// versions and returns the parsed private key.
+func decryptLegacyPKCS8(der []byte, password string) (crypto.PrivateKey, error) {
+	var info encryptedPrivateKeyInfo
+	if _, err := asn1.Unmarshal(der, &info); err != nil {
+		return nil, fmt.Errorf("invalid encrypted PKCS#8 key: %w", err)
+	}
+	var params pbeParameter
+	if _, err := asn1.Unmarshal(info.EncryptionAlgorithm.Parameters.FullBytes, &params); err != nil {
+		return nil, fmt.Errorf("invalid PBE parameters: %w", err)
+	}
+	if params.Iterations < 1 {
+		return nil, fmt.Errorf("invalid PBE iteration count %d", params.Iterations)
+	}
+
+	var block cipher.Block
+	var iv []byte
+	var err error
+	switch alg := info.EncryptionAlgorithm.Algorithm; {
+	case alg.Equal(oidPBEWithMD5AndDESCBC), alg.Equal(oidPBEWithSHA1AndDESCBC):
+		h := md5.New
+		if alg.Equal(oidPBEWithSHA1AndDESCBC) {
+			h = sha1.New
+		}
+		dk := pbkdf1(h, []byte(password), params.Salt, params.Iterations)
+		block, err = des.NewCipher(dk[:8])
+		iv = dk[8:16]
+	case alg.Equal(oidPBEWithSHAAnd3DESCBC):
+		bmp := bmpString(password)
+		key := pkcs12KDF(bmp, params.Salt, params.Iterations, 1, 24)
+		block, err = des.NewTripleDESCipher(key)
+		iv = pkcs12KDF(bmp, params.Salt, params.Iterations, 2, 8)
+	default:
+		return nil, fmt.Errorf("unsupported PKCS#8 encryption algorithm %s", alg)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	data := info.EncryptedData
+	if len(data) == 0 || len(data)%block.BlockSize() != 0 {
+		return nil, fmt.Errorf("invalid encrypted PKCS#8 key length")
+	}
+	plain := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plain, data)
+
+	// a wrong password shows as bad padding or, failing that, as garbage that does not parse
+	pad := int(plain[len(plain)-1])
+	if pad < 1 || pad > block.BlockSize() || !bytes.Equal(plain[len(plain)-pad:], bytes.Repeat([]byte{byte(pad)}, pad)) {
+		return nil, fmt.Errorf("incorrect password")
+	}
+	priv, err := x509.ParsePKCS8PrivateKey(plain[:len(plain)-pad])
+	if err != nil {
+		return nil, fmt.Errorf("incorrect password")
+	}
+	return priv, nil
+}
+
+// pbkdf1 derives 16 bytes of key and IV material as specified in RFC 8018 section 5.1.
+func pbkdf1(h func() hash.Hash, password, salt []byte, iterations int) []byte {
+	d := h()
+	d.Write(password)
+	d.Write(salt)
+	t := d.Sum(nil)
+	for i := 1; i < iterations; i++ {
+		d.Reset()
+		d.Write(t)
+		t = d.Sum(nil)
+	}
+	return t
+}
+
+// bmpString encodes a password as the NUL terminated big-endian UTF-16 string used by PKCS#12.
+func bmpString(password string) []byte {
+	var out []byte
+	for _, c := range utf16.Encode([]rune(password)) {
+		out = append(out, byte(c>>8), byte(c))
+	}
+	return append(out, 0, 0)
+}
+
+// pkcs12KDF derives size bytes of key (id 1) or IV (id 2) material with SHA-1 as specified in RFC 7292
+// appendix B.2.
+func pkcs12KDF(password, salt []byte, iterations int, id byte, size int) []byte {
+	const v = 64 // SHA-1 block size
+
+	fill := func(b []byte) []byte {
+		if len(b) == 0 {
+			return nil
+		}
+		out := make([]byte, v*((len(b)+v-1)/v))
+		for i := range out {
+			out[i] = b[i%len(b)]
+		}
+		return out
+	}
+
+	d := bytes.Repeat([]byte{id}, v)
+	input := append(fill(salt), fill(password)...)
+
+	var out []byte
+	for len(out) < size {
+		sum := sha1.Sum(append(d, input...))
+		a := sum[:]
+		for i := 1; i < iterations; i++ {
+			sum = sha1.Sum(a)
+			a = sum[:]
+		}
+		out = append(out, a...)
+
+		// I_j = (I_j + B + 1) mod 2^(8v) for every v byte block of the input
+		b := fill(a)
+		for j := 0; j < len(input); j += v {
+			carry := 1
+			for k := v - 1; k >= 0; k-- {
+				sum := int(input[j+k]) + int(b[k]) + carry
+				input[j+k] = byte(sum)
+				carry = sum >> 8
+			}
+		}
+	}
+	return out[:size]
+}
+
+// DescribeKeyEncryption describes how the private key in a PEM block is protected, e.g.
+// "legacy PEM DES-EDE3-CBC" or "PKCS#8 PBES2 scrypt (N=16384, r=8, p=1) with AES-256-CBC".
+func DescribeKeyEncryption(block *pem.Block) string {
+	if isLegacyEncryptedPEMBlock(block) {
+		cipherName, _, _ := strings.Cut(block.Headers["DEK-Info"], ",")
+		return "legacy PEM " + cipherName
+	}
+
+	var info encryptedPrivateKeyInfo
+	if block.Type != "ENCRYPTED PRIVATE KEY" {
+		if _, err := asn1.Unmarshal(block.Bytes, &info); err != nil || len(info.EncryptedData) == 0 {
+			return "unencrypted"
+		}
+	} else if _, err := asn1.Unmarshal(block.Bytes, &info); err != nil {
+		return "PKCS#8 with unknown encryption"
+	}
+
+	alg := info.EncryptionAlgorithm.Algorithm
+	if !alg.Equal(oidPBES2) {
+		if name, ok := pkcs8EncryptionSchemeNames[alg.String()]; ok {
+			var params pbeParameter
+			asn1.Unmarshal(info.EncryptionAlgorithm.Parameters.FullBytes, &params)
+			return fmt.Sprintf("PKCS#8 %s (%d iterations)", name, params.Iterations)
+		}
+		return fmt.Sprintf("PKCS#8 with unknown encryption %s", alg)
+	}
+
+	var params struct {
+		KeyDerivationFunc pkix.AlgorithmIdentifier
+		EncryptionScheme  pkix.AlgorithmIdentifier
+	}
+	if _, err := asn1.Unmarshal(info.EncryptionAlgorithm.Parameters.FullBytes, &params); err != nil {
+		return "PKCS#8 PBES2 with invalid parameters"
+	}
+
+	kdf := params.KeyDerivationFunc.Algorithm.String()
+	switch {
+	case params.KeyDerivationFunc.Algorithm.Equal(oidPBKDF2):
+		var p struct {
+			Salt       []byte
+			Iterations int
+			KeyLength  int                      `asn1:"optional"`
+			PRF        pkix.AlgorithmIdentifier `asn1:"optional"`
+		}
+		asn1.Unmarshal(params.KeyDerivationFunc.Parameters.FullBytes, &p)
+		prf := "SHA-1"
+		if p.PRF.Algorithm.Equal(oidHMACWithSHA256) {
+			prf = "SHA-256"
+		} else if len(p.PRF.Algorithm) > 0 {
+			prf = p.PRF.Algorithm.String()
+		}
+		kdf = fmt.Sprintf("PBKDF2-HMAC-%s (%d iterations)", prf, p.Iterations)
+	case params.KeyDerivationFunc.Algorithm.Equal(oidScrypt):
+		var p struct {
+			Salt      []byte
+			N, R, P   int
+			KeyLength int `asn1:"optional"`
+		}
+		asn1.Unmarshal(params.KeyDerivationFunc.Parameters.FullBytes, &p)
+		kdf = fmt.Sprintf("scrypt (N=%d, r=%d, p=%d)", p.N, p.R, p.P)
+	}
+
+	enc := params.EncryptionScheme.Algorithm.String()
+	if name, ok := pkcs8EncryptionSchemeNames[enc]; ok {
+		enc = name
+	}
+	return fmt.Sprintf("PKCS#8 PBES2 %s with %s", kdf, enc)
+}
+
+// KeyEncryptionOptions configures the encryption of a PKCS#8 private key by MarshalEncryptedPKCS8.
+type KeyEncryptionOptions struct {
+	KDF              string // "scrypt" or "pbkdf2"
+	Cipher           string // "aes-256-cbc" or "aes-256-gcm"
+	ScryptN          int    // scrypt CPU/memory cost, a power of two
+	ScryptR          int    // scrypt block size
+	ScryptP          int    // scrypt parallelization
+	PBKDF2Iterations int    // PBKDF2-HMAC-SHA256 iterations
+}
+
+// MarshalEncryptedPKCS8 encrypts a private key as a PBES2 "ENCRYPTED PRIVATE KEY" PEM block.
+func MarshalEncryptedPKCS8(key crypto.Signer, password string, opts KeyEncryptionOptions) ([]byte, error) {
+	if password == "" {
+		return nil, fmt.Errorf("password is empty")
+	}
+
+	var encOpts pkcs8.Opts
+	switch opts.Cipher {
+	case "aes-256-cbc":
+		encOpts.Cipher = pkcs8.AES256CBC
+	case "aes-256-gcm":
+		encOpts.Cipher = pkcs8.AES256GCM
+	default:
+		return nil, fmt.Errorf("unsupported cipher %q, expected aes-256-cbc or aes-256-gcm", opts.Cipher)
+	}
+
+	switch opts.KDF {
+	case "scrypt":
+		if opts.ScryptN < 2 || opts.ScryptN&(opts.ScryptN-1) != 0 {
+			return nil, fmt.Errorf("scrypt N must be a power of two greater than 1")
+		}
+		if opts.ScryptR < 1 || opts.ScryptP < 1 {
+			return nil, fmt.Errorf("scrypt r and p must be positive")
+		}
+		encOpts.KDFOpts = pkcs8.ScryptOpts{
+			SaltSize:                 16,
+			CostParameter:            opts.ScryptN,
+			BlockSize:                opts.ScryptR,
+			ParallelizationParameter: opts.ScryptP,
+		}
+	case "pbkdf2":
+		if opts.PBKDF2Iterations < 1 {
+			return nil, fmt.Errorf("PBKDF2 iterations must be positive")
+		}
+		encOpts.KDFOpts = pkcs8.PBKDF2Opts{
+			SaltSize:       16,
+			IterationCount: opts.PBKDF2Iterations,
+			HMACHash:       crypto.SHA256,
+		}
+	default:
+		return nil, fmt.Errorf("unsupported key derivation function %q, expected scrypt or pbkdf2", opts.KDF)
+	}
+
+	der, err := pkcs8.MarshalPrivateKey(key, []byte(password), &encOpts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt private key: %w", err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "ENCRYPTED PRIVATE KEY", Bytes: der}), nil
+}
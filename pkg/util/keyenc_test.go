@@ -0,0 +1,149 @@
+package util
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// The legacy-*.pem fixtures hold the key in legacy-plain.pem, encrypted with the password "revokr" by
+//
+//	openssl pkcs8 -topk8 -in legacy-plain.pem -v1 <scheme> -passout pass:revokr -provider legacy -provider default
+
+func TestParsePrivateSignerLegacyPKCS8(t *testing.T) {
+	want, err := ParsePrivateSigner("testdata/legacy-plain.pem", "")
+	if err != nil {
+		t.Fatalf("failed to parse unencrypted fixture: %v", err)
+	}
+
+	tests := []struct {
+		file        string
+		description string
+	}{
+		{"legacy-pbe-sha1-3des.pem", "PKCS#8 PKCS#12 PBE SHA-1 and 3DES-CBC (2048 iterations)"},
+		{"legacy-pbe-md5-des.pem", "PKCS#8 PBES1 MD5 and DES-CBC (2048 iterations)"},
+		{"legacy-pbe-sha1-des.pem", "PKCS#8 PBES1 SHA-1 and DES-CBC (2048 iterations)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			path := filepath.Join("testdata", tt.file)
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			block, _ := pem.Decode(data)
+			if block == nil {
+				t.Fatal("fixture is not PEM")
+			}
+			if got := DescribeKeyEncryption(block); got != tt.description {
+				t.Errorf("DescribeKeyEncryption = %q, want %q", got, tt.description)
+			}
+			if !isLegacyPKCS8(block.Bytes) {
+				t.Error("fixture is not detected as legacy PKCS#8")
+			}
+
+			key, err := ParsePrivateSigner(path, "revokr")
+			if err != nil {
+				t.Fatalf("failed to decrypt: %v", err)
+			}
+			if !key.(*ecdsa.PrivateKey).Equal(want) {
+				t.Error("decrypted key does not match the unencrypted fixture")
+			}
+
+			if _, err := ParsePrivateSigner(path, "wrong"); err == nil || !strings.Contains(err.Error(), "incorrect password") {
+				t.Errorf("wrong password: got %v, want an incorrect password error", err)
+			}
+			if _, err := ParsePrivateSigner(path, ""); err == nil {
+				t.Error("missing password: got no error")
+			}
+		})
+	}
+}
+
+func TestBMPString(t *testing.T) {
+	// RFC 7292 appendix B.1
+	got := bmpString("Beavis")
+	want := []byte{0x00, 0x42, 0x00, 0x65, 0x00, 0x61, 0x00, 0x76, 0x00, 0x69, 0x00, 0x73, 0x00, 0x00}
+	if string(got) != string(want) {
+		t.Errorf("bmpString(%q) = % x, want % x", "Beavis", got, want)
+	}
+}
+
+// privateKey is implemented by the private keys of the standard library.
+type privateKey interface {
+	crypto.Signer
+	Equal(crypto.PrivateKey) bool
+}
+
+func TestMarshalEncryptedPKCS8RoundTrip(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		opts        KeyEncryptionOptions
+		description string
+	}{
+		{
+			name:        "scrypt aes-256-cbc",
+			opts:        KeyEncryptionOptions{KDF: "scrypt", Cipher: "aes-256-cbc", ScryptN: 1024, ScryptR: 8, ScryptP: 1},
+			description: "PKCS#8 PBES2 scrypt (N=1024, r=8, p=1) with AES-256-CBC",
+		},
+		{
+			name:        "scrypt aes-256-gcm",
+			opts:        KeyEncryptionOptions{KDF: "scrypt", Cipher: "aes-256-gcm", ScryptN: 1024, ScryptR: 8, ScryptP: 1},
+			description: "PKCS#8 PBES2 scrypt (N=1024, r=8, p=1) with AES-256-GCM",
+		},
+		{
+			name:        "pbkdf2 aes-256-cbc",
+			opts:        KeyEncryptionOptions{KDF: "pbkdf2", Cipher: "aes-256-cbc", PBKDF2Iterations: 1000},
+			description: "PKCS#8 PBES2 PBKDF2-HMAC-SHA-256 (1000 iterations) with AES-256-CBC",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []privateKey{ecKey, rsaKey} {
+				data, err := MarshalEncryptedPKCS8(key, "revokr", tt.opts)
+				if err != nil {
+					t.Fatalf("failed to encrypt %T: %v", key, err)
+				}
+
+				block, _ := pem.Decode(data)
+				if got := DescribeKeyEncryption(block); got != tt.description {
+					t.Errorf("DescribeKeyEncryption = %q, want %q", got, tt.description)
+				}
+
+				path := filepath.Join(t.TempDir(), "key.pem")
+				if err := os.WriteFile(path, data, 0o600); err != nil {
+					t.Fatal(err)
+				}
+				parsed, err := ParsePrivateSigner(path, "revokr")
+				if err != nil {
+					t.Fatalf("failed to parse encrypted %T: %v", key, err)
+				}
+				if !key.Equal(parsed) {
+					t.Errorf("%T does not round trip", key)
+				}
+				if _, err := ParsePrivateSigner(path, "wrong"); err == nil {
+					t.Errorf("%T decrypted with the wrong password", key)
+				}
+			}
+		})
+	}
+}
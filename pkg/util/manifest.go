@@ -0,0 +1,188 @@
+package util
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// RevocationRecord is a single entry of a revocation manifest.
+type RevocationRecord struct {
+	Serial         string    // normalized hexadecimal serial number (see NormalizeSerial)
+	Reason         int       // RFC 5280 reason code
+	RevocationTime time.Time // zero means "use the CRL's this-update time"
+	InvalidityDate time.Time // zero means no invalidity date extension
+	Comment        string    // free-text note, never written to the CRL
+
+	// Issuer is the CA that issued the revoked certificate when it is not the CRL issuer (indirect CRLs)
+	Issuer *x509.Certificate
+}
+
+// manifestJSONRecord is the JSON representation of a RevocationRecord.
+type manifestJSONRecord struct {
+	Serial         string `json:"serial"`
+	Reason         string `json:"reason"`
+	RevocationTime string `json:"revocation_time"`
+	InvalidityDate string `json:"invalidity_date"`
+	Comment        string `json:"comment"`
+	Issuer         string `json:"issuer"`
+}
+
+// manifestColumns lists the recognized CSV header names.
+var manifestColumns = []string{"serial", "reason", "revocation_time", "invalidity_date", "comment", "issuer"}
+
+// ReadRevocationManifest reads a CSV or JSON revocation manifest. JSON manifests are an array of
+// objects, CSV manifests must start with a header row naming the columns. The format is chosen by
+// file extension, falling back to sniffing the content. The optional issuer of an entry is the path
+// to the certificate of the CA that issued the revoked certificate, relative to the manifest.
+func ReadRevocationManifest(path string) ([]RevocationRecord, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	var raw []manifestJSONRecord
+
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext == ".json" || (ext != ".csv" && bytes.HasPrefix(bytes.TrimSpace(data), []byte("["))) {
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return nil, fmt.Errorf("failed to parse JSON revocation manifest: %w", err)
+		}
+	} else {
+		raw, err = readManifestCSV(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse CSV revocation manifest: %w", err)
+		}
+	}
+
+	var records []RevocationRecord
+	seen := make(map[string]struct{})
+	issuers := make(map[string]*x509.Certificate)
+
+	for i, r := range raw {
+		record, err := parseManifestRecord(r)
+		if err != nil {
+			return nil, fmt.Errorf("invalid manifest entry %d: %w", i+1, err)
+		}
+
+		var issuerKey string
+		if issuerPath := strings.TrimSpace(r.Issuer); issuerPath != "" {
+			if !filepath.IsAbs(issuerPath) {
+				issuerPath = filepath.Join(filepath.Dir(path), issuerPath)
+			}
+			if _, ok := issuers[issuerPath]; !ok {
+				if issuers[issuerPath], err = ParseCertificate(issuerPath); err != nil {
+					return nil, fmt.Errorf("invalid issuer of manifest entry %d: %w", i+1, err)
+				}
+			}
+			record.Issuer = issuers[issuerPath]
+			issuerKey = string(record.Issuer.RawSubject)
+		}
+
+		if _, ok := seen[issuerKey+"/"+record.Serial]; ok {
+			log.Warn().Str("serial", record.Serial).Msg("duplicate serial in revocation manifest, skipping")
+			continue
+		}
+		seen[issuerKey+"/"+record.Serial] = struct{}{}
+
+		records = append(records, record)
+	}
+
+	log.Debug().Msgf("Read %d revocation entries from manifest %q", len(records), path)
+	return records, nil
+}
+
+func readManifestCSV(data []byte) ([]manifestJSONRecord, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.Comment = '#'
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		known := false
+		for _, col := range manifestColumns {
+			if name == col {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown column %q in header", name)
+		}
+		columns[name] = i
+	}
+	if _, ok := columns["serial"]; !ok {
+		return nil, fmt.Errorf("header must contain a %q column", "serial")
+	}
+
+	field := func(row []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+
+	var records []manifestJSONRecord
+	for {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		records = append(records, manifestJSONRecord{
+			Serial:         field(row, "serial"),
+			Reason:         field(row, "reason"),
+			RevocationTime: field(row, "revocation_time"),
+			InvalidityDate: field(row, "invalidity_date"),
+			Comment:        field(row, "comment"),
+			Issuer:         field(row, "issuer"),
+		})
+	}
+
+	return records, nil
+}
+
+func parseManifestRecord(r manifestJSONRecord) (RevocationRecord, error) {
+	var record RevocationRecord
+	var err error
+
+	serial, ok := NormalizeSerial(r.Serial)
+	if !ok {
+		return record, fmt.Errorf("invalid serial number %q", r.Serial)
+	}
+	record.Serial = serial
+
+	if record.Reason, err = ParseReason(r.Reason); err != nil {
+		return record, err
+	}
+
+	if record.RevocationTime, err = ParseTime(strings.TrimSpace(r.RevocationTime)); err != nil {
+		return record, fmt.Errorf("invalid revocation time: %w", err)
+	}
+
+	if record.InvalidityDate, err = ParseTime(strings.TrimSpace(r.InvalidityDate)); err != nil {
+		return record, fmt.Errorf("invalid invalidity date: %w", err)
+	}
+
+	record.Comment = r.Comment
+	return record, nil
+}
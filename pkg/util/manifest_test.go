@@ -0,0 +1,182 @@
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeManifest(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write manifest: %v", err)
+	}
+	return path
+}
+
+func TestReadRevocationManifest(t *testing.T) {
+	revoked := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	invalid := time.Date(2024, 2, 28, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    []RevocationRecord
+	}{
+		{
+			name: "csv all columns",
+			file: "revoked.csv",
+			content: "serial,reason,revocation_time,invalidity_date,comment\n" +
+				"0x0A1B,keyCompromise,2024-03-01T12:30:00Z,2024-02-28,laptop stolen\n",
+			want: []RevocationRecord{
+				{Serial: "a1b", Reason: ReasonKeyCompromise, RevocationTime: revoked, InvalidityDate: invalid, Comment: "laptop stolen"},
+			},
+		},
+		{
+			name: "csv reordered columns with comments and defaults",
+			file: "revoked.csv",
+			content: "# revoked by ops\n" +
+				"Reason, Serial\n" +
+				"superseded, 01\n" +
+				", ff\n",
+			want: []RevocationRecord{
+				{Serial: "1", Reason: ReasonSuperseded},
+				{Serial: "ff", Reason: ReasonUnspecified},
+			},
+		},
+		{
+			name: "csv numeric reason and duplicate serial",
+			file: "revoked.csv",
+			content: "serial,reason\n" +
+				"10,6\n" +
+				"0x10,keyCompromise\n",
+			want: []RevocationRecord{
+				{Serial: "10", Reason: ReasonCertificateHold},
+			},
+		},
+		{
+			name: "json",
+			file: "revoked.json",
+			content: `[
+				{"serial": "0a1b", "reason": "KEYCOMPROMISE", "revocation_time": "2024-03-01T12:30:00Z", "invalidity_date": "2024-02-28"},
+				{"serial": "2", "reason": "cessationOfOperation", "comment": "decommissioned"}
+			]`,
+			want: []RevocationRecord{
+				{Serial: "a1b", Reason: ReasonKeyCompromise, RevocationTime: revoked, InvalidityDate: invalid},
+				{Serial: "2", Reason: ReasonCessationOfOperation, Comment: "decommissioned"},
+			},
+		},
+		{
+			name:    "json sniffed without extension",
+			file:    "revoked",
+			content: ` [{"serial": "3", "reason": "privilegeWithdrawn"}]`,
+			want: []RevocationRecord{
+				{Serial: "3", Reason: ReasonPrivilegeWithdrawn},
+			},
+		},
+		{
+			name:    "csv header only",
+			file:    "revoked.csv",
+			content: "serial\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, err := ReadRevocationManifest(writeManifest(t, tt.file, tt.content))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(records) != len(tt.want) {
+				t.Fatalf("got %d records, want %d", len(records), len(tt.want))
+			}
+			for i, want := range tt.want {
+				got := records[i]
+				if got.Serial != want.Serial || got.Reason != want.Reason || got.Comment != want.Comment ||
+					!got.RevocationTime.Equal(want.RevocationTime) || !got.InvalidityDate.Equal(want.InvalidityDate) {
+					t.Errorf("record %d = %+v, want %+v", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestReadRevocationManifestInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		errText string
+	}{
+		{
+			name:    "unknown reason name",
+			file:    "revoked.csv",
+			content: "serial,reason\n01,stolen\n",
+			errText: `unknown reason: "stolen"`,
+		},
+		{
+			name:    "unknown reason code",
+			file:    "revoked.csv",
+			content: "serial,reason\n01,7\n",
+			errText: "unknown reason code: 7",
+		},
+		{
+			name:    "bad revocation time",
+			file:    "revoked.csv",
+			content: "serial,revocation_time\n01,yesterday\n",
+			errText: "invalid revocation time",
+		},
+		{
+			name:    "bad invalidity date",
+			file:    "revoked.json",
+			content: `[{"serial": "01", "invalidity_date": "2024-13-45"}]`,
+			errText: "invalid invalidity date",
+		},
+		{
+			name:    "bad serial",
+			file:    "revoked.json",
+			content: `[{"serial": "xyz"}]`,
+			errText: `invalid serial number "xyz"`,
+		},
+		{
+			name:    "unknown column",
+			file:    "revoked.csv",
+			content: "serial,why\n01,because\n",
+			errText: `unknown column "why"`,
+		},
+		{
+			name:    "missing serial column",
+			file:    "revoked.csv",
+			content: "reason\nkeyCompromise\n",
+			errText: `must contain a "serial" column`,
+		},
+		{
+			name:    "malformed json",
+			file:    "revoked.json",
+			content: `[{"serial": "01"`,
+			errText: "failed to parse JSON revocation manifest",
+		},
+		{
+			name:    "missing issuer certificate",
+			file:    "revoked.json",
+			content: `[{"serial": "01", "issuer": "missing.pem"}]`,
+			errText: "invalid issuer of manifest entry 1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadRevocationManifest(writeManifest(t, tt.file, tt.content))
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if !strings.Contains(err.Error(), tt.errText) {
+				t.Errorf("error %q does not contain %q", err, tt.errText)
+			}
+		})
+	}
+}
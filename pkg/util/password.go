@@ -0,0 +1,203 @@
+package util
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/goodieshq/revokr/pkg/shamir"
+	"github.com/jschauma/getpass"
+	"github.com/rs/zerolog/log"
+)
+
+// ReadPassword reads a password or PIN from a source:
+//
+//	prompt              the controlling TTY, see PromptPassword
+//	env:<name>          the environment variable <name>
+//	file:<path>         the first line of a file
+//	fd:<n>              the first line read from an inherited file descriptor
+//	cmd:<command>       the first line printed by a shell command, which gets the prompt as "$1"
+//	                    (like SSH_ASKPASS programs) and inherits stdin, see ShellCommand
+//	pinentry:<command>  a pinentry program speaking the Assuan protocol, e.g. pinentry-curses
+//	shamir              Shamir shares prompted from each custodian in turn, see ReadShamirPassword
+//
+// The env, file and fd sources are read with getpass. The prompt describes what is asked for
+// and is shown by interactive sources.
+func ReadPassword(source, prompt string) (string, error) {
+	kind, arg, _ := strings.Cut(source, ":")
+	if kind != "prompt" && kind != "shamir" && arg == "" {
+		return "", fmt.Errorf("invalid password source %q, expected <type>:<value>", source)
+	}
+
+	switch kind {
+	case "prompt", "shamir":
+		if arg != "" {
+			return "", fmt.Errorf("password source %s takes no value", kind)
+		}
+		if kind == "shamir" {
+			return ReadShamirPassword(prompt)
+		}
+		return PromptPassword(prompt)
+
+	case "env", "file", "fd":
+		password, err := getpass.Getpass(source)
+		if err != nil {
+			return "", fmt.Errorf("failed to read password from %s: %w", kind, err)
+		}
+		return password, nil
+
+	case "cmd":
+		// not getpass, which needs a controlling TTY for the command and trims the output
+		cmd := ShellCommand(arg, prompt)
+		cmd.Stdin = os.Stdin
+		cmd.Stderr = os.Stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("password command failed: %w", err)
+		}
+		return readFirstLine(bytes.NewReader(out))
+
+	case "pinentry":
+		return readPinentry(arg, prompt)
+
+	default:
+		return "", fmt.Errorf("unsupported password source %q", kind)
+	}
+}
+
+// maxShareAttempts is the number of times in a row a custodian may enter an invalid share.
+const maxShareAttempts = 3
+
+// ReadShamirPassword prompts the custodians in turn for their Shamir shares, as printed by the split
+// command, until the threshold recorded in the shares is reached and reconstructs the password in memory.
+// Shares are not echoed and a mistyped share is asked for again.
+func ReadShamirPassword(prompt string) (string, error) {
+	var shares []shamir.Share
+	defer func() {
+		for _, share := range shares {
+			clear(share.Value)
+		}
+	}()
+
+	for failures := 0; len(shares) == 0 || len(shares) < shares[0].Threshold; {
+		label := fmt.Sprintf("%s (share %d)", prompt, len(shares)+1)
+		if len(shares) > 0 {
+			label = fmt.Sprintf("%s (share %d of %d)", prompt, len(shares)+1, shares[0].Threshold)
+		}
+		text, err := PromptPassword(label)
+		if err != nil {
+			return "", err
+		}
+
+		share, err := shamir.ParseShare(text)
+		if err == nil {
+			err = shamir.Compatible(shares, share)
+		}
+		if err != nil {
+			failures++
+			if failures >= maxShareAttempts {
+				return "", fmt.Errorf("invalid share: %w", err)
+			}
+			log.Warn().Err(err).Msg("Invalid share, please enter it again")
+			continue
+		}
+
+		failures = 0
+		shares = append(shares, share)
+	}
+
+	secret, err := shamir.Combine(shares)
+	if err != nil {
+		return "", err
+	}
+	defer clear(secret)
+
+	log.Info().Int("shares", len(shares)).Msg("Reconstructed password from Shamir shares")
+	return string(secret), nil
+}
+
+// readFirstLine returns the first line of r without its line ending. Any further lines are ignored.
+func readFirstLine(r io.Reader) (string, error) {
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", fmt.Errorf("failed to read password: %w", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// readPinentry asks a pinentry program for a password using the Assuan protocol.
+func readPinentry(command, prompt string) (string, error) {
+	cmd := ShellCommand(command)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return "", err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return "", err
+	}
+	if err := cmd.Start(); err != nil {
+		return "", fmt.Errorf("failed to start pinentry: %w", err)
+	}
+	defer func() {
+		stdin.Close()
+		cmd.Wait()
+	}()
+
+	r := bufio.NewReader(stdout)
+
+	// send a command, or none for the greeting, and read the response up to OK or ERR
+	transact := func(command string) (string, error) {
+		if command != "" {
+			if _, err := fmt.Fprintf(stdin, "%s\n", command); err != nil {
+				return "", fmt.Errorf("failed to write to pinentry: %w", err)
+			}
+		}
+		var data strings.Builder
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return "", fmt.Errorf("failed to read from pinentry: %w", err)
+			}
+			line = strings.TrimRight(line, "\r\n")
+			switch {
+			case line == "OK" || strings.HasPrefix(line, "OK "):
+				return data.String(), nil
+			case strings.HasPrefix(line, "ERR "):
+				return "", fmt.Errorf("pinentry: %s", strings.TrimPrefix(line, "ERR "))
+			case strings.HasPrefix(line, "D "):
+				value, err := url.PathUnescape(strings.TrimPrefix(line, "D "))
+				if err != nil {
+					return "", fmt.Errorf("invalid pinentry data: %w", err)
+				}
+				data.WriteString(value)
+			}
+			// status (S), comment (#) and inquiry lines are ignored
+		}
+	}
+
+	if _, err := transact(""); err != nil {
+		return "", err
+	}
+	for _, command := range []string{
+		"SETTITLE revokr",
+		"SETDESC " + assuanEscape(prompt),
+		"SETPROMPT " + assuanEscape("Password:"),
+	} {
+		if _, err := transact(command); err != nil {
+			return "", err
+		}
+	}
+
+	return transact("GETPIN")
+}
+
+// assuanEscape percent-encodes the characters that cannot appear in an Assuan command argument.
+func assuanEscape(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
@@ -0,0 +1,106 @@
+package util
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestReadPassword(t *testing.T) {
+	t.Setenv("REVOKR_TEST_PASSWORD", "from env")
+
+	path := filepath.Join(t.TempDir(), "password.txt")
+	if err := os.WriteFile(path, []byte("from file\nsecond line\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if _, err := w.WriteString("from fd\r\nsecond line\n"); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"env:REVOKR_TEST_PASSWORD", "from env"},
+		{"file:" + path, "from file"},
+		{fmt.Sprintf("fd:%d", r.Fd()), "from fd"},
+	}
+	for _, tt := range tests {
+		got, err := ReadPassword(tt.source, "Password")
+		if err != nil {
+			t.Errorf("ReadPassword(%q) failed: %v", tt.source, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ReadPassword(%q) = %q, want %q", tt.source, got, tt.want)
+		}
+	}
+
+	for _, source := range []string{"env:REVOKR_TEST_UNSET", "file:" + path + ".missing", "fd:x", "env", "cmd", "prompt:x", "vault:x"} {
+		if _, err := ReadPassword(source, "Password"); err == nil {
+			t.Errorf("ReadPassword(%q) succeeded, want an error", source)
+		}
+	}
+}
+
+func TestReadPasswordCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the commands are POSIX shell commands")
+	}
+
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"cmd:echo from cmd", "from cmd"},
+		{"cmd:printf ' spaced \\r\\nsecond line'", " spaced "},
+		{"cmd:printf 'no line ending'", "no line ending"},
+		{`cmd:echo "$1"`, "Password"},
+		{"cmd:printf ''", ""},
+	}
+	for _, tt := range tests {
+		got, err := ReadPassword(tt.source, "Password")
+		if err != nil {
+			t.Errorf("ReadPassword(%q) failed: %v", tt.source, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ReadPassword(%q) = %q, want %q", tt.source, got, tt.want)
+		}
+	}
+	if _, err := ReadPassword("cmd:exit 3", "Password"); err == nil {
+		t.Error("ReadPassword of a failing command succeeded, want an error")
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if _, err := w.WriteString("from stdin\n"); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	stdin := os.Stdin
+	os.Stdin = r
+	defer func() { os.Stdin = stdin }()
+
+	// the command runs without a TTY and reads the stdin of revokr
+	got, err := ReadPassword("cmd:read -r password && echo \"$password\"", "Password")
+	if err != nil {
+		t.Fatalf("ReadPassword failed: %v", err)
+	}
+	if got != "from stdin" {
+		t.Errorf("ReadPassword = %q, want %q", got, "from stdin")
+	}
+}
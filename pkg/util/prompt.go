@@ -1,20 +1,34 @@
 package util
 
 import (
+	"bufio"
 	"fmt"
+	"os"
 	"strings"
 
 	"github.com/jschauma/getpass"
 )
 
+// stdinReader is shared by prompts reading from stdin, so input buffered for one prompt is not lost.
+var stdinReader = bufio.NewReader(os.Stdin)
+
+// PromptPassword prompts for a password on the controlling TTY without echoing it. Without a TTY the
+// prompt is written to stderr and the password is read from stdin, so stdout stays free for output.
 func PromptPassword(prompt string) (string, error) {
 	if !strings.HasSuffix(prompt, ": ") {
 		prompt += ": "
 	}
-	fmt.Print(prompt)
-	p, err := getpass.Getpass()
-	if err != nil {
-		return "", err
+
+	if tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0); err == nil {
+		tty.Close()
+		return getpass.Getpass("tty:" + prompt)
+	}
+
+	fmt.Fprint(os.Stderr, prompt)
+	line, err := stdinReader.ReadString('\n')
+	fmt.Fprintln(os.Stderr)
+	if err != nil && line == "" {
+		return "", fmt.Errorf("failed to read password from stdin: %w", err)
 	}
-	return p, nil
+	return strings.TrimRight(line, "\r\n"), nil
 }
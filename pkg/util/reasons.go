@@ -0,0 +1,66 @@
+package util
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// CRL entry reason codes as defined in RFC 5280 section 5.3.1.
+const (
+	ReasonUnspecified          = 0
+	ReasonKeyCompromise        = 1
+	ReasonCACompromise         = 2
+	ReasonAffiliationChanged   = 3
+	ReasonSuperseded           = 4
+	ReasonCessationOfOperation = 5
+	ReasonCertificateHold      = 6
+	ReasonRemoveFromCRL        = 8
+	ReasonPrivilegeWithdrawn   = 9
+	ReasonAACompromise         = 10
+)
+
+var reasonNames = map[int]string{
+	ReasonUnspecified:          "unspecified",
+	ReasonKeyCompromise:        "keyCompromise",
+	ReasonCACompromise:         "cACompromise",
+	ReasonAffiliationChanged:   "affiliationChanged",
+	ReasonSuperseded:           "superseded",
+	ReasonCessationOfOperation: "cessationOfOperation",
+	ReasonCertificateHold:      "certificateHold",
+	ReasonRemoveFromCRL:        "removeFromCRL",
+	ReasonPrivilegeWithdrawn:   "privilegeWithdrawn",
+	ReasonAACompromise:         "aACompromise",
+}
+
+// ReasonString returns the RFC 5280 name of a CRL reason code.
+func ReasonString(code int) string {
+	if name, ok := reasonNames[code]; ok {
+		return name
+	}
+	return fmt.Sprintf("unknown(%d)", code)
+}
+
+// ParseReason parses a CRL reason code from its RFC 5280 name (case-insensitive) or its decimal value.
+// An empty string is treated as unspecified.
+func ParseReason(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return ReasonUnspecified, nil
+	}
+
+	if code, err := strconv.Atoi(s); err == nil {
+		if _, ok := reasonNames[code]; !ok {
+			return 0, fmt.Errorf("unknown reason code: %d", code)
+		}
+		return code, nil
+	}
+
+	for code, name := range reasonNames {
+		if strings.EqualFold(name, s) {
+			return code, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown reason: %q", s)
+}
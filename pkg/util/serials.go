@@ -57,6 +57,17 @@ func DedupSerialNumbers(entries []x509.RevocationListEntry, serialsInclude, seri
 	return serials
 }
 
+// NormalizeSerial converts a hexadecimal serial number (with an optional 0x prefix) into the
+// lowercase form used as a key throughout revokr. Returns false if the serial is not valid hex.
+func NormalizeSerial(serial string) (string, bool) {
+	serial = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(serial)), "0x")
+	n, good := new(big.Int).SetString(serial, 16)
+	if !good {
+		return "", false
+	}
+	return n.Text(16), true
+}
+
 func ReadSerialNumbersFromFile(path string) ([]string, error) {
 	var serials []string
 	if path == "" { // no serials file provided, return empty CRL list
@@ -69,15 +80,14 @@ func ReadSerialNumbersFromFile(path string) ([]string, error) {
 	}
 
 	for _, line := range strings.Split(string(data), "\n") {
-		line := strings.ToLower(strings.TrimSpace(line))
+		line := strings.TrimSpace(line)
 		if line != "" {
-			line = strings.TrimPrefix(line, "0x")
-			_, good := new(big.Int).SetString(line, 16)
+			serial, good := NormalizeSerial(line)
 			if !good {
 				log.Warn().Str("serial", line).Msg("invalid serial number format, skipping")
 				continue
 			}
-			serials = append(serials, line)
+			serials = append(serials, serial)
 		}
 	}
 
@@ -0,0 +1,11 @@
+//go:build !windows
+
+package util
+
+import "os/exec"
+
+// ShellCommand returns a command running a command line with /bin/sh -c. The args are passed to the
+// shell as "$1", "$2" and so on.
+func ShellCommand(command string, args ...string) *exec.Cmd {
+	return exec.Command("/bin/sh", append([]string{"-c", command, "revokr"}, args...)...)
+}
@@ -0,0 +1,20 @@
+package util
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// ShellCommand returns a command running a command line with cmd.exe /C. cmd.exe has no positional
+// parameters, so the args are not passed.
+func ShellCommand(command string, args ...string) *exec.Cmd {
+	shell := os.Getenv("ComSpec")
+	if shell == "" {
+		shell = "cmd.exe"
+	}
+	cmd := exec.Command(shell)
+	// cmd.exe does its own parsing, so the command line is passed as is instead of quoted for argv
+	cmd.SysProcAttr = &syscall.SysProcAttr{CmdLine: `"` + shell + `" /S /C "` + command + `"`}
+	return cmd
+}
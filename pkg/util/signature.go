@@ -0,0 +1,182 @@
+package util
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+	"unicode"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/crypto/cryptobyte"
+	cryptobyte_asn1 "golang.org/x/crypto/cryptobyte/asn1"
+)
+
+// SignatureFormat describes how a signature produced by an external signer is encoded.
+type SignatureFormat string
+
+const (
+	SignatureFormatAuto   SignatureFormat = "auto"   // detect the encoding and the ECDSA signature form
+	SignatureFormatDER    SignatureFormat = "der"    // binary, ECDSA signatures as DER ECDSA-Sig-Value
+	SignatureFormatRaw    SignatureFormat = "raw"    // binary, ECDSA signatures as fixed-width r||s
+	SignatureFormatHex    SignatureFormat = "hex"    // hex encoded, ECDSA signature form is detected
+	SignatureFormatBase64 SignatureFormat = "base64" // base64 or base64url encoded, ECDSA signature form is detected
+)
+
+var signatureFormats = []SignatureFormat{SignatureFormatAuto, SignatureFormatDER, SignatureFormatRaw, SignatureFormatHex, SignatureFormatBase64}
+
+// ParseSignatureFormat parses a signature format name, case-insensitively.
+func ParseSignatureFormat(name string) (SignatureFormat, error) {
+	var names []string
+	for _, format := range signatureFormats {
+		if strings.EqualFold(string(format), name) {
+			return format, nil
+		}
+		names = append(names, string(format))
+	}
+	return "", fmt.Errorf("unsupported signature format %q (supported: %s)", name, strings.Join(names, ", "))
+}
+
+// ReadSignatureFile reads a signature file and returns the signature in the form expected in a CRL's
+// signature BIT STRING. Text encodings are decoded and ECDSA signatures given as r||s are converted
+// to DER using the curve size of the issuer's public key.
+func ReadSignatureFile(path string, format SignatureFormat, pub crypto.PublicKey) ([]byte, error) {
+	block, err := TryParsePEM(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signature file: %w", err)
+	}
+
+	data := block.Bytes
+	if block.Type == "" {
+		// PEM blocks are always binary, anything else may still be text encoded
+		if data, err = decodeSignature(data, format); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(data) == 0 {
+		return nil, fmt.Errorf("signature data is empty")
+	}
+
+	if pub, ok := pub.(*ecdsa.PublicKey); ok {
+		return ecdsaSignatureToDER(data, pub, format)
+	}
+
+	if format == SignatureFormatRaw {
+		log.Warn().Msg("the raw signature format only applies to ECDSA signatures")
+	}
+	return data, nil
+}
+
+// decodeSignature removes the text encoding of a signature, if any.
+func decodeSignature(data []byte, format SignatureFormat) ([]byte, error) {
+	text := strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, string(data))
+
+	switch format {
+	case SignatureFormatDER, SignatureFormatRaw:
+		return data, nil
+	case SignatureFormatHex:
+		sig, err := hex.DecodeString(strings.TrimPrefix(text, "0x"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode hex signature: %w", err)
+		}
+		return sig, nil
+	case SignatureFormatBase64:
+		sig, ok := decodeBase64(text)
+		if !ok {
+			return nil, fmt.Errorf("failed to decode base64 signature")
+		}
+		return sig, nil
+	}
+
+	// auto: binary signatures are practically never valid hex or base64 text
+	if sig, err := hex.DecodeString(strings.TrimPrefix(text, "0x")); err == nil && len(sig) > 0 {
+		log.Debug().Msg("Decoded hex signature data")
+		return sig, nil
+	}
+	if sig, ok := decodeBase64(text); ok && len(sig) > 0 {
+		log.Debug().Msg("Decoded base64 signature data")
+		return sig, nil
+	}
+	log.Debug().Msg("Read binary signature data")
+	return data, nil
+}
+
+// decodeBase64 decodes standard or URL-safe base64, with or without padding.
+func decodeBase64(text string) ([]byte, bool) {
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		if sig, err := enc.DecodeString(text); err == nil {
+			return sig, true
+		}
+	}
+	return nil, false
+}
+
+// RawECDSASignatureToDER converts a fixed-width r||s ECDSA signature to a DER ECDSA-Sig-Value.
+func RawECDSASignatureToDER(sig []byte, pub *ecdsa.PublicKey) ([]byte, error) {
+	return ecdsaSignatureToDER(sig, pub, SignatureFormatRaw)
+}
+
+// ECDSASignatureToDER returns an ECDSA signature as a DER ECDSA-Sig-Value, detecting whether it is
+// given in DER or fixed-width r||s form.
+func ECDSASignatureToDER(sig []byte, pub *ecdsa.PublicKey) ([]byte, error) {
+	return ecdsaSignatureToDER(sig, pub, SignatureFormatAuto)
+}
+
+// ecdsaSignatureToDER returns an ECDSA signature as a DER ECDSA-Sig-Value, converting it from the
+// fixed-width r||s form used by PKCS#11, cloud KMS and WebCrypto if needed.
+func ecdsaSignatureToDER(sig []byte, pub *ecdsa.PublicKey, format SignatureFormat) ([]byte, error) {
+	size := (pub.Curve.Params().BitSize + 7) / 8
+
+	if format != SignatureFormatRaw && isECDSASigValue(sig) {
+		return sig, nil
+	}
+	if format == SignatureFormatDER {
+		return nil, fmt.Errorf("signature is not a DER encoded ECDSA-Sig-Value")
+	}
+
+	if len(sig) != 2*size {
+		return nil, fmt.Errorf("raw ECDSA signature is %d bytes, expected %d bytes for curve %s", len(sig), 2*size, pub.Curve.Params().Name)
+	}
+
+	log.Debug().Msg("Converting raw r||s ECDSA signature to DER")
+	r := new(big.Int).SetBytes(sig[:size])
+	s := new(big.Int).SetBytes(sig[size:])
+
+	var b cryptobyte.Builder
+	b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+		b.AddASN1BigInt(r)
+		b.AddASN1BigInt(s)
+	})
+	return b.Bytes()
+}
+
+// isECDSASigValue reports whether sig is exactly one DER encoded ECDSA-Sig-Value.
+func isECDSASigValue(sig []byte) bool {
+	input := cryptobyte.String(sig)
+
+	var seq cryptobyte.String
+	r, s := new(big.Int), new(big.Int)
+	if !input.ReadASN1(&seq, cryptobyte_asn1.SEQUENCE) || !input.Empty() ||
+		!seq.ReadASN1Integer(r) || !seq.ReadASN1Integer(s) || !seq.Empty() {
+		return false
+	}
+
+	// re-encode to reject BER and other non-canonical encodings
+	var b cryptobyte.Builder
+	b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+		b.AddASN1BigInt(r)
+		b.AddASN1BigInt(s)
+	})
+	der, err := b.Bytes()
+	return err == nil && bytes.Equal(der, sig)
+}
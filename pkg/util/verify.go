@@ -1,12 +1,17 @@
 package util
 
 import (
+	"bytes"
 	"crypto"
 	"crypto/ecdsa"
 	"crypto/ed25519"
 	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/x509"
 	"fmt"
+	"strings"
+
+	"github.com/rs/zerolog/log"
 )
 
 func VerifyCrtKeyMatch(crt *x509.Certificate, key crypto.Signer) error {
@@ -36,7 +41,7 @@ func VerifyCrtKeyMatch(crt *x509.Certificate, key crypto.Signer) error {
 		if kp.X.Cmp(cp.X) != 0 || kp.Y.Cmp(cp.Y) != 0 {
 			return fmt.Errorf("ECDSA public key in certificate does not match private key")
 		}
-	case *ed25519.PublicKey:
+	case ed25519.PublicKey:
 		cp, ok := crt.PublicKey.(ed25519.PublicKey)
 		if !ok {
 			return fmt.Errorf("certificate public key is not Ed25519")
@@ -49,3 +54,52 @@ func VerifyCrtKeyMatch(crt *x509.Certificate, key crypto.Signer) error {
 	}
 	return nil
 }
+
+// VerifyCASerials checks that none of the serials belongs to an end-entity certificate issued by the
+// issuer, as required for an authority revocation list. Serials without a matching certificate in
+// issued are only reported as a warning.
+func VerifyCASerials(issuer *x509.Certificate, issued []*x509.Certificate, serials []string) error {
+	bySerial := make(map[string]*x509.Certificate)
+	for _, crt := range issued {
+		if !IssuedBy(crt, issuer) {
+			continue // not issued by this CA
+		}
+		bySerial[crt.SerialNumber.Text(16)] = crt
+	}
+
+	for _, serial := range serials {
+		crt, ok := bySerial[serial]
+		if !ok {
+			log.Warn().Str("serial", serial).Msg("no issued certificate found for serial, cannot verify it is a CA certificate")
+			continue
+		}
+		if !crt.BasicConstraintsValid || !crt.IsCA {
+			return fmt.Errorf("serial %s belongs to end-entity certificate %q", serial, crt.Subject.String())
+		}
+	}
+
+	return nil
+}
+
+// IssuedBy reports whether crt names issuer as its issuer, matching the issuer name and, when both
+// certificates carry them, the authority and subject key identifiers. The signature is deliberately
+// not checked so certificates signed with algorithms Go refuses to verify (e.g. SHA-1) still match.
+func IssuedBy(crt, issuer *x509.Certificate) bool {
+	if !bytes.Equal(crt.RawIssuer, issuer.RawSubject) {
+		return false
+	}
+	if len(crt.AuthorityKeyId) > 0 && len(issuer.SubjectKeyId) > 0 {
+		return bytes.Equal(crt.AuthorityKeyId, issuer.SubjectKeyId)
+	}
+	return true
+}
+
+// Fingerprint returns the SHA-256 fingerprint of a certificate as colon separated hex.
+func Fingerprint(crt *x509.Certificate) string {
+	sum := sha256.Sum256(crt.Raw)
+	parts := make([]string, len(sum))
+	for i, b := range sum {
+		parts[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(parts, ":")
+}
@@ -0,0 +1,46 @@
+package util
+
+import (
+	"crypto/x509"
+	"strings"
+	"testing"
+)
+
+func mustParseCertificate(t *testing.T, path string) *x509.Certificate {
+	t.Helper()
+	crt, err := ParseCertificate(path)
+	if err != nil {
+		t.Fatalf("failed to parse %s: %v", path, err)
+	}
+	return crt
+}
+
+func TestVerifyCASerialsSHA1(t *testing.T) {
+	// sha1-leaf.crt is an end-entity certificate with serial 1234 signed with ecdsa-with-SHA1, which
+	// CheckSignatureFrom refuses. It must still be recognized as issued by sha1-root.crt.
+	root := mustParseCertificate(t, "testdata/sha1-root.crt")
+	otherRoot := mustParseCertificate(t, "testdata/sha1-other-root.crt")
+	leaf := mustParseCertificate(t, "testdata/sha1-leaf.crt")
+
+	if leaf.CheckSignatureFrom(root) == nil {
+		t.Fatal("expected Go to refuse the SHA-1 signature of the fixture")
+	}
+
+	err := VerifyCASerials(root, []*x509.Certificate{leaf}, []string{"1234"})
+	if err == nil || !strings.Contains(err.Error(), "end-entity certificate") {
+		t.Fatalf("expected end-entity serial to be refused, got %v", err)
+	}
+
+	// A CA with the same name but another key is not the issuer
+	if IssuedBy(leaf, otherRoot) {
+		t.Error("leaf matched a CA with the same name but a different subject key identifier")
+	}
+	if err := VerifyCASerials(otherRoot, []*x509.Certificate{leaf}, []string{"1234"}); err != nil {
+		t.Errorf("unexpected error for a certificate of another CA: %v", err)
+	}
+
+	// Serials without an issued certificate are not an error
+	if err := VerifyCASerials(root, []*x509.Certificate{leaf}, []string{"abcd"}); err != nil {
+		t.Errorf("unexpected error for an unknown serial: %v", err)
+	}
+}